@@ -0,0 +1,433 @@
+package balancer
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+)
+
+var ErrNoHost = errors.New("no available host")
+
+// Host 一个可以调用的地址，Addr 为 client.NewClient 使用的地址，如 tcp://10.0.0.1:5600
+type Host struct {
+	Addr string
+	//Weight 权重，0 按 1 处理
+	Weight int
+	//Zone 所在的可用区/机房
+	Zone string
+}
+
+// Source 提供服务当前的地址列表
+type Source interface {
+	Hosts() ([]*Host, error)
+}
+
+// SourceFunc 将函数作为 Source 使用
+type SourceFunc func() ([]*Host, error)
+
+func (f SourceFunc) Hosts() ([]*Host, error) {
+	return f()
+}
+
+// Static 固定的地址列表
+type Static []*Host
+
+func (s Static) Hosts() ([]*Host, error) {
+	return s, nil
+}
+
+// NewStatic 使用权重为 1 的地址创建固定的地址列表
+func NewStatic(addrs ...string) Static {
+	hosts := make(Static, len(addrs))
+	for i, addr := range addrs {
+		hosts[i] = &Host{Addr: addr, Weight: 1}
+	}
+	return hosts
+}
+
+// Endpoint 均衡器中的一个地址及其调用状态，地址列表刷新后同一 Addr 的状态保留
+type Endpoint struct {
+	Host     *Host
+	inflight int64
+	breaker  breaker
+	//added 地址加入列表的时间，第一次刷新得到的地址为零值
+	added   time.Time
+	latency peakEWMA
+}
+
+// Inflight 正在进行的调用数
+func (endpoint *Endpoint) Inflight() int64 {
+	return atomic.LoadInt64(&endpoint.inflight)
+}
+
+// Request 一次选择的参数
+type Request struct {
+	//Key 一致性哈希等策略使用的键
+	Key string
+	//Session 会话保持使用的键，如用户 Id，见 NewSticky
+	Session string
+	//Exclude 不参与选择的地址，如重试时已经尝试过的地址
+	Exclude []string
+	//Deadline 不为零时整个调用(包括重试与备份请求)的截止时间，每次调用的超时不超过剩余的时间
+	Deadline time.Time
+}
+
+func (request *Request) excluded(addr string) bool {
+
+	if request == nil {
+		return false
+	}
+
+	for _, exclude := range request.Exclude {
+		if exclude == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (request *Request) deadline() time.Time {
+
+	if request == nil {
+		return time.Time{}
+	}
+
+	return request.Deadline
+}
+
+// Balancer 定期从 Source 刷新地址列表，按 Strategy 为每次调用选择地址
+type Balancer struct {
+	strategy  Strategy
+	source    Source
+	refresh   time.Duration
+	lock      sync.RWMutex
+	endpoints []*Endpoint
+	updated   time.Time
+	breaker   *BreakerConfig
+	//只使用 ranked 中排在前面的 subsetSize 个可用地址
+	subsetId   string
+	subsetSize int
+	ranked     []*Endpoint
+	slowStart  time.Duration
+	aggression float64
+	zone       string
+	healthy    float64
+	//refreshing 避免同时从 Source 刷新
+	refreshing int32
+	onRemove   []func(endpoint *Endpoint)
+}
+
+// New 创建均衡器，strategy 为空时使用 RoundRobin，默认每 5 秒刷新一次地址列表
+func New(strategy Strategy, source Source) *Balancer {
+
+	if strategy == nil {
+		strategy = NewRoundRobin()
+	}
+
+	balancer := new(Balancer)
+	balancer.strategy = strategy
+	balancer.source = source
+	balancer.refresh = 5 * time.Second
+	return balancer
+}
+
+// SetRefresh 设置刷新地址列表的间隔，为 0 表示只在 Refresh 时刷新
+func (balancer *Balancer) SetRefresh(interval time.Duration) {
+	balancer.lock.Lock()
+	balancer.refresh = interval
+	balancer.lock.Unlock()
+}
+
+// OnRemove 刷新后地址不在列表中时调用，被移除的地址不再被选择，已经开始的调用可能仍在进行
+func (balancer *Balancer) OnRemove(handler func(endpoint *Endpoint)) {
+	balancer.lock.Lock()
+	balancer.onRemove = append(balancer.onRemove, handler)
+	balancer.lock.Unlock()
+}
+
+// SetBreaker 开启单个地址的熔断，熔断的地址不参与选择，传入 nil 关闭
+func (balancer *Balancer) SetBreaker(config *BreakerConfig) {
+	balancer.lock.Lock()
+	balancer.breaker = config
+	balancer.lock.Unlock()
+}
+
+// SetSubset 每个客户端只使用 size 个地址，限制大规模服务上的连接数，size 为 0 表示使用所有地址
+// 地址按 id(如本机的主机名)与地址的哈希(rendezvous)排序，不同 id 的客户端分散到不同的地址上
+// 排在前面的地址被排除或熔断时由后面的地址替代，地址列表变化时只有少量客户端改变使用的地址
+func (balancer *Balancer) SetSubset(id string, size int) {
+	balancer.lock.Lock()
+	balancer.subsetId = id
+	balancer.subsetSize = size
+	balancer.rank()
+	balancer.lock.Unlock()
+}
+
+func (balancer *Balancer) rank() {
+
+	if balancer.subsetSize <= 0 {
+		balancer.ranked = nil
+		return
+	}
+
+	ranked := make([]*Endpoint, len(balancer.endpoints))
+	scores := make(map[*Endpoint]uint64, len(ranked))
+
+	for i, endpoint := range balancer.endpoints {
+		ranked[i] = endpoint
+		scores[endpoint] = hashKey(balancer.subsetId + "/" + endpoint.Host.Addr)
+	}
+
+	sort.Slice(ranked, func(i, j int) bool { return scores[ranked[i]] > scores[ranked[j]] })
+	balancer.ranked = ranked
+}
+
+// SetSlowStart 新加入的地址在 window 时间内逐渐增加流量，避免刚启动的服务(如未预热的 PHP opcache)被大量请求压垮
+// 加入 t 时间后被选择的机会为 (t/window)^(1/aggression)，aggression 为 1 时线性增加，大于 1 时增加得更快
+// 第一次刷新得到的地址不受影响，window 为 0 表示关闭
+func (balancer *Balancer) SetSlowStart(window time.Duration, aggression float64) {
+
+	if aggression <= 0 {
+		aggression = 1
+	}
+
+	balancer.lock.Lock()
+	balancer.slowStart = window
+	balancer.aggression = aggression
+	balancer.lock.Unlock()
+}
+
+// 预热中的地址被保留为候选地址的概率
+func warmup(endpoint *Endpoint, window time.Duration, aggression float64, now time.Time) float64 {
+
+	if window <= 0 || endpoint.added.IsZero() {
+		return 1
+	}
+
+	elapsed := now.Sub(endpoint.added)
+
+	if elapsed >= window {
+		return 1
+	}
+
+	//保留少量的流量，使预热期间也能调用到该地址
+	return math.Max(0.1, math.Pow(float64(elapsed)/float64(window), 1/aggression))
+}
+
+// 按预热的进度随机去掉预热中的地址，至少保留一个地址
+func (balancer *Balancer) warmed(candidates []*Endpoint, window time.Duration, aggression float64, now time.Time) []*Endpoint {
+
+	kept := make([]*Endpoint, 0, len(candidates))
+
+	for _, endpoint := range candidates {
+		if rand.Float64() < warmup(endpoint, window, aggression, now) {
+			kept = append(kept, endpoint)
+		}
+	}
+
+	if len(kept) < 1 {
+		return candidates
+	}
+
+	return kept
+}
+
+// Refresh 立即从 Source 刷新地址列表，失败时保留原来的列表
+func (balancer *Balancer) Refresh() error {
+
+	hosts, err := balancer.source.Hosts()
+
+	if err != nil {
+		return err
+	}
+
+	balancer.lock.Lock()
+
+	current := make(map[string]*Endpoint, len(balancer.endpoints))
+
+	for _, endpoint := range balancer.endpoints {
+		current[endpoint.Host.Addr] = endpoint
+	}
+
+	endpoints := make([]*Endpoint, 0, len(hosts))
+
+	for _, host := range hosts {
+
+		endpoint, ok := current[host.Addr]
+
+		if !ok {
+			endpoint = new(Endpoint)
+			if !balancer.updated.IsZero() {
+				endpoint.added = time.Now()
+			}
+		}
+
+		endpoint.Host = host
+		endpoints = append(endpoints, endpoint)
+	}
+
+	for _, host := range hosts {
+		delete(current, host.Addr)
+	}
+
+	balancer.endpoints = endpoints
+	balancer.updated = time.Now()
+	balancer.rank()
+
+	//在锁内调用，保证并发刷新时按顺序更新
+	if updater, ok := balancer.strategy.(Updater); ok {
+		updater.Update(endpoints)
+	}
+
+	handlers := balancer.onRemove
+	balancer.lock.Unlock()
+
+	//current 中剩下的是被移除的地址
+	for _, endpoint := range current {
+		for _, handler := range handlers {
+			handler(endpoint)
+		}
+	}
+
+	return nil
+}
+
+// 列表中是否有该地址，不触发刷新
+func (balancer *Balancer) contains(addr string) bool {
+
+	balancer.lock.RLock()
+	defer balancer.lock.RUnlock()
+
+	for _, endpoint := range balancer.endpoints {
+		if endpoint.Host.Addr == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Endpoints 当前的地址列表
+func (balancer *Balancer) Endpoints() []*Endpoint {
+
+	balancer.refreshIfExpired()
+
+	balancer.lock.RLock()
+	defer balancer.lock.RUnlock()
+	return balancer.endpoints
+}
+
+// 第一次使用时同步刷新，之后过期时在后台刷新
+func (balancer *Balancer) refreshIfExpired() {
+
+	balancer.lock.RLock()
+	empty := balancer.updated.IsZero()
+	expired := balancer.refresh > 0 && time.Since(balancer.updated) > balancer.refresh
+	balancer.lock.RUnlock()
+
+	if empty {
+		balancer.Refresh()
+		return
+	}
+
+	if expired && atomic.CompareAndSwapInt32(&balancer.refreshing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&balancer.refreshing, 0)
+			balancer.Refresh()
+		}()
+	}
+}
+
+// Pick 选择一个地址，调用结束后必须调用返回的 done，err 为调用的结果
+func (balancer *Balancer) Pick(request *Request) (*Endpoint, func(err *yar.Error), error) {
+
+	endpoints := balancer.Endpoints()
+
+	balancer.lock.RLock()
+	config := balancer.breaker
+	size := balancer.subsetSize
+	window, aggression := balancer.slowStart, balancer.aggression
+	zone, healthy := balancer.zone, balancer.healthy
+
+	if size > 0 {
+		endpoints = balancer.ranked
+	}
+
+	balancer.lock.RUnlock()
+
+	now := time.Now()
+	var candidates []*Endpoint
+	var probe *Endpoint
+	//同一可用区中参与选择的地址数
+	var local int
+
+	for _, endpoint := range endpoints {
+
+		if size > 0 && len(candidates) >= size {
+			break
+		}
+
+		if request.excluded(endpoint.Host.Addr) {
+			continue
+		}
+
+		if len(zone) > 0 && endpoint.Host.Zone == zone {
+			local++
+		}
+
+		if config == nil || !endpoint.Open() {
+			candidates = append(candidates, endpoint)
+			continue
+		}
+
+		//熔断超时的地址由本次调用探测是否恢复
+		if probe == nil && endpoint.breaker.allow(config, now) {
+			probe = endpoint
+		}
+	}
+
+	if len(zone) > 0 && local > 0 {
+		candidates = localized(candidates, zone, local, healthy)
+	}
+
+	if window > 0 && len(candidates) > 1 {
+		candidates = balancer.warmed(candidates, window, aggression, now)
+	}
+
+	endpoint := probe
+	probing := probe != nil
+
+	if endpoint == nil && len(candidates) > 0 {
+		endpoint = balancer.strategy.Pick(candidates, request)
+	}
+
+	if endpoint == nil {
+		return nil, nil, ErrNoHost
+	}
+
+	atomic.AddInt64(&endpoint.inflight, 1)
+
+	var once sync.Once
+
+	done := func(err *yar.Error) {
+		once.Do(func() {
+			atomic.AddInt64(&endpoint.inflight, -1)
+			//没有到达服务端的调用(如连接失败)不计入延迟
+			if err == nil || !err.Retriable() {
+				endpoint.latency.observe(time.Since(now), time.Now())
+			}
+			if config != nil {
+				endpoint.breaker.record(config, err, time.Now(), probing)
+			}
+		})
+	}
+
+	return endpoint, done, nil
+}
@@ -0,0 +1,559 @@
+package balancer
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+)
+
+func TestRoundRobin(t *testing.T) {
+
+	b := New(NewRoundRobin(), NewStatic("tcp://a:1", "tcp://b:1", "tcp://c:1"))
+	counts := make(map[string]int)
+
+	for i := 0; i < 30; i++ {
+		endpoint, done, err := b.Pick(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[endpoint.Host.Addr]++
+		done(nil)
+	}
+
+	for addr, n := range counts {
+		if n != 10 {
+			t.Fatal(addr, n)
+		}
+	}
+
+	if _, _, err := b.Pick(&Request{Exclude: []string{"tcp://a:1", "tcp://b:1", "tcp://c:1"}}); err != ErrNoHost {
+		t.Fatal("excluded hosts picked", err)
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+
+	b := New(NewLeastConnections(), NewStatic("tcp://a:1", "tcp://b:1"))
+
+	busy, done, _ := b.Pick(nil)
+
+	//a 或 b 有一个正在进行的调用时总是选择另一个
+	for i := 0; i < 10; i++ {
+		endpoint, release, _ := b.Pick(nil)
+		if endpoint == busy {
+			t.Fatal("busy host picked")
+		}
+		release(nil)
+	}
+
+	done(nil)
+	done(nil)
+
+	if busy.Inflight() != 0 {
+		t.Fatal("inflight not released", busy.Inflight())
+	}
+}
+
+func TestRefreshKeepsState(t *testing.T) {
+
+	var lock sync.Mutex
+	hosts := NewStatic("tcp://a:1")
+
+	b := New(nil, SourceFunc(func() ([]*Host, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return hosts, nil
+	}))
+
+	first, _, _ := b.Pick(nil)
+
+	lock.Lock()
+	hosts = NewStatic("tcp://a:1", "tcp://b:1")
+	lock.Unlock()
+
+	if err := b.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.Endpoints()) != 2 || b.Endpoints()[0] != first || first.Inflight() != 1 {
+		t.Fatal("endpoint state lost on refresh")
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+
+	strategy := NewConsistentHash(50, 0)
+	b := New(strategy, NewStatic("tcp://a:1", "tcp://b:1", "tcp://c:1"))
+
+	picked := make(map[string]string)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("user-", i)
+		endpoint, done, _ := b.Pick(&Request{Key: key})
+		done(nil)
+		picked[key] = endpoint.Host.Addr
+	}
+
+	//去掉一个地址后，其它地址上的键不变
+	b = New(strategy, NewStatic("tcp://a:1", "tcp://b:1"))
+
+	for key, addr := range picked {
+		endpoint, done, _ := b.Pick(&Request{Key: key})
+		done(nil)
+		if addr != "tcp://c:1" && endpoint.Host.Addr != addr {
+			t.Fatal(key, "moved from", addr, "to", endpoint.Host.Addr)
+		}
+	}
+}
+
+func TestConsistentHashExclude(t *testing.T) {
+
+	strategy := NewConsistentHash(50, 0).(*consistentHash)
+	b := New(strategy, NewStatic("tcp://a:1", "tcp://b:1", "tcp://c:1"))
+	removed := New(NewConsistentHash(50, 0), NewStatic("tcp://a:1", "tcp://b:1"))
+
+	b.Refresh()
+	ring := strategy.ring
+
+	//排除 c 时 c 上的键移到环上的下一个地址，与 c 下线后的结果相同，哈希环不重建
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("user-", i)
+		endpoint, done, _ := b.Pick(&Request{Key: key, Exclude: []string{"tcp://c:1"}})
+		done(nil)
+		expected, done, _ := removed.Pick(&Request{Key: key})
+		done(nil)
+		if endpoint.Host.Addr != expected.Host.Addr {
+			t.Fatal(key, endpoint.Host.Addr, expected.Host.Addr)
+		}
+	}
+
+	if &strategy.ring[0] != &ring[0] {
+		t.Fatal("ring rebuilt for excluded hosts")
+	}
+}
+
+func TestConsistentHashBoundedLoad(t *testing.T) {
+
+	b := New(NewConsistentHash(50, 1.25), NewStatic("tcp://a:1", "tcp://b:1", "tcp://c:1", "tcp://d:1"))
+
+	var releases []func(err *yar.Error)
+	counts := make(map[string]int)
+
+	//同一个键的调用在超出负载上限后分散到其它地址
+	for i := 0; i < 40; i++ {
+		endpoint, done, _ := b.Pick(&Request{Key: "hot"})
+		counts[endpoint.Host.Addr]++
+		releases = append(releases, done)
+	}
+
+	for addr, n := range counts {
+		if n > 13 {
+			t.Fatal(addr, n)
+		}
+	}
+
+	for _, done := range releases {
+		done(nil)
+	}
+}
+
+func TestSticky(t *testing.T) {
+
+	var lock sync.Mutex
+	hosts := NewStatic("tcp://a:1", "tcp://b:1", "tcp://c:1")
+
+	b := New(NewSticky(nil, time.Minute), SourceFunc(func() ([]*Host, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return hosts, nil
+	}))
+
+	first, done, _ := b.Pick(&Request{Session: "s1"})
+	done(nil)
+
+	for i := 0; i < 5; i++ {
+		endpoint, done, _ := b.Pick(&Request{Session: "s1"})
+		done(nil)
+		if endpoint != first {
+			t.Fatal("session moved to", endpoint.Host.Addr)
+		}
+	}
+
+	//绑定的地址下线后重新绑定，之后保持在新的地址上
+	var remaining Static
+	for _, host := range hosts {
+		if host.Addr != first.Host.Addr {
+			remaining = append(remaining, host)
+		}
+	}
+
+	lock.Lock()
+	hosts = remaining
+	lock.Unlock()
+	b.Refresh()
+
+	second, done, _ := b.Pick(&Request{Session: "s1"})
+	done(nil)
+
+	if second.Host.Addr == first.Host.Addr {
+		t.Fatal("session kept on removed host")
+	}
+
+	for i := 0; i < 5; i++ {
+		endpoint, done, _ := b.Pick(&Request{Session: "s1"})
+		done(nil)
+		if endpoint != second {
+			t.Fatal("session not re-pinned")
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+
+	b := New(NewRoundRobin(), NewStatic("tcp://a:1", "tcp://b:1"))
+
+	config := NewBreakerConfig()
+	config.Failures = 3
+	config.OpenTimeout = 50 * time.Millisecond
+	b.SetBreaker(config)
+
+	failure := yar.NewError(yar.ErrorNetwork, "connection refused")
+
+	for i := 0; i < 6; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		if endpoint.Host.Addr == "tcp://a:1" {
+			done(failure)
+		} else {
+			done(nil)
+		}
+	}
+
+	//a 熔断后只选择 b
+	for i := 0; i < 5; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		if endpoint.Host.Addr != "tcp://b:1" {
+			t.Fatal("open host picked")
+		}
+	}
+
+	//超时后第一个调用探测 a，成功后恢复
+	time.Sleep(60 * time.Millisecond)
+
+	probe, done, _ := b.Pick(nil)
+
+	if probe.Host.Addr != "tcp://a:1" || !probe.Open() {
+		t.Fatal("recovery probe not sent to open host")
+	}
+
+	done(nil)
+
+	if probe.Open() {
+		t.Fatal("breaker not closed after successful probe")
+	}
+}
+
+func TestBreakerStaleResult(t *testing.T) {
+
+	b := New(NewRoundRobin(), NewStatic("tcp://a:1", "tcp://b:1"))
+
+	config := NewBreakerConfig()
+	config.Failures = 1
+	config.OpenTimeout = 50 * time.Millisecond
+	b.SetBreaker(config)
+
+	pickA := func() (*Endpoint, func(err *yar.Error)) {
+		for {
+			endpoint, done, _ := b.Pick(nil)
+			if endpoint.Host.Addr == "tcp://a:1" {
+				return endpoint, done
+			}
+			done(nil)
+		}
+	}
+
+	//熔断之前开始的调用，探测期间才结束
+	_, stale := pickA()
+	a, done := pickA()
+	done(yar.NewError(yar.ErrorNetwork, "connection refused"))
+
+	time.Sleep(60 * time.Millisecond)
+
+	probe, probeDone, _ := b.Pick(nil)
+
+	if probe != a {
+		t.Fatal("recovery probe not sent to open host")
+	}
+
+	stale(nil)
+
+	if !a.Open() {
+		t.Fatal("breaker closed by a call started before it opened")
+	}
+
+	probeDone(nil)
+
+	if a.Open() {
+		t.Fatal("breaker not closed after successful probe")
+	}
+}
+
+func TestSubset(t *testing.T) {
+
+	var addrs []string
+	for i := 0; i < 20; i++ {
+		addrs = append(addrs, fmt.Sprint("tcp://10.0.0.", i, ":5600"))
+	}
+
+	used := make(map[string]bool)
+	b := New(nil, NewStatic(addrs...))
+	b.SetSubset("client-1", 3)
+
+	for i := 0; i < 30; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		used[endpoint.Host.Addr] = true
+	}
+
+	if len(used) != 3 {
+		t.Fatal("subset size", len(used))
+	}
+
+	//被排除的地址由排在后面的地址替代
+	var exclude []string
+	for addr := range used {
+		exclude = append(exclude, addr)
+	}
+
+	endpoint, done, err := b.Pick(&Request{Exclude: exclude})
+
+	if err != nil || used[endpoint.Host.Addr] {
+		t.Fatal("subset not refilled", err)
+	}
+
+	done(nil)
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+
+	hosts := Static{
+		{Addr: "tcp://canary:1", Weight: 1},
+		{Addr: "tcp://a:1", Weight: 10},
+		{Addr: "tcp://b:1", Weight: 9},
+	}
+
+	b := New(NewWeightedRoundRobin(), hosts)
+	counts := make(map[string]int)
+	last, run := "", 0
+
+	for i := 0; i < 200; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		counts[endpoint.Host.Addr]++
+		if endpoint.Host.Addr == last {
+			run++
+		} else {
+			last, run = endpoint.Host.Addr, 1
+		}
+		if run > 2 {
+			t.Fatal("weighted picks not smooth")
+		}
+	}
+
+	if counts["tcp://canary:1"] != 10 || counts["tcp://a:1"] != 100 || counts["tcp://b:1"] != 90 {
+		t.Fatal(counts)
+	}
+}
+
+func TestSlowStart(t *testing.T) {
+
+	var lock sync.Mutex
+	hosts := NewStatic("tcp://a:1", "tcp://b:1")
+
+	b := New(nil, SourceFunc(func() ([]*Host, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return hosts, nil
+	}))
+	b.SetSlowStart(time.Hour, 1)
+	b.Refresh()
+
+	lock.Lock()
+	hosts = NewStatic("tcp://a:1", "tcp://b:1", "tcp://new:1")
+	lock.Unlock()
+	b.Refresh()
+
+	//刚加入的地址只得到很少的流量
+	picked := 0
+
+	for i := 0; i < 300; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		if endpoint.Host.Addr == "tcp://new:1" {
+			picked++
+		}
+	}
+
+	if picked < 1 || picked > 40 {
+		t.Fatal("new host picked", picked)
+	}
+}
+
+func TestLocality(t *testing.T) {
+
+	hosts := Static{
+		&Host{Addr: "tcp://a:1", Zone: "z1"},
+		&Host{Addr: "tcp://b:1", Zone: "z1"},
+		&Host{Addr: "tcp://c:1", Zone: "z2"},
+	}
+
+	b := New(nil, hosts)
+	b.SetLocality("z1", 1)
+
+	config := NewBreakerConfig()
+	config.Failures = 1
+	config.OpenTimeout = time.Hour
+	b.SetBreaker(config)
+
+	for i := 0; i < 20; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		if endpoint.Host.Zone != "z1" {
+			t.Fatal("remote host picked while local hosts are healthy")
+		}
+	}
+
+	for {
+		endpoint, done, _ := b.Pick(nil)
+		if endpoint.Host.Addr == "tcp://a:1" {
+			done(yar.NewError(yar.ErrorNetwork, "connection refused"))
+			break
+		}
+		done(nil)
+	}
+
+	//本可用区只有一半的地址可用，约一半的调用分流到其它可用区
+	remote := 0
+
+	for i := 0; i < 400; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		if endpoint.Host.Zone != "z1" {
+			remote++
+		}
+	}
+
+	if remote < 120 || remote > 280 {
+		t.Fatal("unexpected spillover", remote)
+	}
+}
+
+func TestPeakEWMA(t *testing.T) {
+
+	b := New(NewPeakEWMA(), NewStatic("tcp://slow:1", "tcp://fast:1"))
+	now := time.Now()
+
+	for _, endpoint := range b.Endpoints() {
+		if endpoint.Host.Addr == "tcp://slow:1" {
+			endpoint.latency.observe(50*time.Millisecond, now)
+		} else {
+			endpoint.latency.observe(5*time.Millisecond, now)
+		}
+	}
+
+	fast := 0
+
+	for i := 0; i < 100; i++ {
+		endpoint, done, _ := b.Pick(nil)
+		done(nil)
+		if endpoint.Host.Addr == "tcp://fast:1" {
+			fast++
+		}
+	}
+
+	if fast < 95 {
+		t.Fatal("slow host picked too often", 100-fast)
+	}
+
+	//耗时超过估计值时立即取该耗时
+	for _, endpoint := range b.Endpoints() {
+		if endpoint.Host.Addr == "tcp://fast:1" {
+			endpoint.latency.observe(200*time.Millisecond, time.Now())
+			if endpoint.Latency() < 150*time.Millisecond {
+				t.Fatal("latency peak not recorded", endpoint.Latency())
+			}
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+
+	limiter := NewLimiter(4, 1, 100)
+	var releases []func(err *yar.Error)
+
+	for i := 0; i < 4; i++ {
+		release, ok := limiter.Acquire()
+		if !ok {
+			t.Fatal("acquire under limit rejected")
+		}
+		releases = append(releases, release)
+	}
+
+	if _, ok := limiter.Acquire(); ok {
+		t.Fatal("acquire over limit accepted")
+	}
+
+	//失败的调用降低限制
+	for _, release := range releases {
+		release(yar.NewError(yar.ErrorNetwork, "timeout"))
+	}
+
+	if limiter.Limit() >= 4 || limiter.Inflight() != 0 {
+		t.Fatal("limit not reduced", limiter.Limit(), limiter.Inflight())
+	}
+
+	//耗时平稳且调用数接近限制时放宽限制
+	for i := 0; i < 50; i++ {
+		var batch []func(err *yar.Error)
+		for {
+			release, ok := limiter.Acquire()
+			if !ok {
+				break
+			}
+			batch = append(batch, release)
+		}
+		for _, release := range batch {
+			release(nil)
+		}
+	}
+
+	if limiter.Limit() < 10 {
+		t.Fatal("limit not increased", limiter.Limit())
+	}
+}
+
+func TestLimiterFloor(t *testing.T) {
+
+	//min 与 initial 为 0 时仍然至少允许一个调用
+	limiter := NewLimiter(0, 0, 10)
+	failure := yar.NewError(yar.ErrorNetwork, "timeout")
+
+	for i := 0; i < 200; i++ {
+		release, ok := limiter.Acquire()
+		if !ok {
+			t.Fatal("all calls rejected after failures", i, limiter.Limit())
+		}
+		release(failure)
+	}
+
+	if limiter.Limit() < 1 {
+		t.Fatal("limit dropped below 1", limiter.Limit())
+	}
+
+	if limiter := NewLimiter(100, 1, 10); limiter.Limit() != 10 {
+		t.Fatal("initial not clamped to max", limiter.Limit())
+	}
+}
@@ -0,0 +1,134 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+)
+
+// BreakerConfig 单个地址的熔断参数
+type BreakerConfig struct {
+	//Window 统计失败率的时间窗口
+	Window time.Duration
+	//MinRequests 窗口内至少有该数量的调用时才按 Ratio 判断
+	MinRequests int
+	//Ratio 窗口内失败调用的比例达到该值时熔断
+	Ratio float64
+	//Failures 连续失败该次数时熔断，为 0 表示不按连续失败判断
+	Failures int
+	//OpenTimeout 熔断后经过该时间允许一个探测调用，成功后恢复，失败后继续熔断
+	OpenTimeout time.Duration
+}
+
+func NewBreakerConfig() *BreakerConfig {
+	config := new(BreakerConfig)
+	config.Window = 10 * time.Second
+	config.MinRequests = 20
+	config.Ratio = 0.5
+	config.Failures = 5
+	config.OpenTimeout = 5 * time.Second
+	return config
+}
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	//breakerHalfOpen 探测调用正在进行，其它调用不选择该地址
+	breakerHalfOpen
+)
+
+type breaker struct {
+	lock        sync.Mutex
+	state       int
+	opened      time.Time
+	window      time.Time
+	requests    int
+	failures    int
+	consecutive int
+}
+
+// 是否可以选择该地址，熔断超时后第一个调用作为探测调用
+func (b *breaker) allow(config *BreakerConfig, now time.Time) bool {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.opened) < config.OpenTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+
+	return true
+}
+
+// 只有网络错误等可重试的错误计为地址的失败，服务端返回的业务错误不计入
+// probe 为 true 表示该调用是 allow 放行的探测调用，探测期间只有探测调用的结果决定是否恢复
+// 熔断之前开始、探测期间才结束的调用的结果被忽略
+func (b *breaker) record(config *BreakerConfig, err *yar.Error, now time.Time, probe bool) {
+
+	failed := err != nil && err.Retriable()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == breakerHalfOpen {
+		if !probe {
+			return
+		}
+		if failed {
+			b.state = breakerOpen
+			b.opened = now
+		} else {
+			b.reset(breakerClosed, now)
+		}
+		return
+	}
+
+	if b.state == breakerOpen {
+		return
+	}
+
+	if now.Sub(b.window) > config.Window {
+		b.window = now
+		b.requests = 0
+		b.failures = 0
+	}
+
+	b.requests++
+
+	if !failed {
+		b.consecutive = 0
+		return
+	}
+
+	b.failures++
+	b.consecutive++
+
+	if (config.Failures > 0 && b.consecutive >= config.Failures) ||
+		(b.requests >= config.MinRequests && float64(b.failures) >= config.Ratio*float64(b.requests)) {
+		b.reset(breakerOpen, now)
+		b.opened = now
+	}
+}
+
+func (b *breaker) reset(state int, now time.Time) {
+	b.state = state
+	b.window = now
+	b.requests = 0
+	b.failures = 0
+	b.consecutive = 0
+}
+
+// Open 该地址当前是否处于熔断状态(包括正在探测)
+func (endpoint *Endpoint) Open() bool {
+	endpoint.breaker.lock.Lock()
+	defer endpoint.breaker.lock.Unlock()
+	return endpoint.breaker.state != breakerClosed
+}
@@ -0,0 +1,212 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/client"
+)
+
+// Client 按均衡器选择的地址发起调用，每个地址使用一个 client.Client
+type Client struct {
+	balancer  *Balancer
+	configure func(c *client.Client)
+	lock      sync.Mutex
+	clients   map[string]*client.Client
+	retry     *RetryPolicy
+	limiter   *Limiter
+	hedge     time.Duration
+	drain     time.Duration
+}
+
+// RetryPolicy 调用失败后换一个地址重试，已经尝试过的地址不再选择
+type RetryPolicy struct {
+	//Attempts 最多调用的次数，包括第一次
+	Attempts int
+	//Budget 从第一次调用开始的总时间，每次调用的超时不超过剩余的时间，用完后不再重试，为 0 表示不限制
+	Budget time.Duration
+	//Retriable 判断错误是否可以重试，为空时按 yar.Error.Retriable
+	//网络错误时请求可能已经被处理，非幂等的方法应当在这里排除
+	Retriable func(method string, err *yar.Error) bool
+}
+
+func NewRetryPolicy() *RetryPolicy {
+	policy := new(RetryPolicy)
+	policy.Attempts = 3
+	policy.Budget = 0
+	policy.Retriable = nil
+	return policy
+}
+
+func (policy *RetryPolicy) retriable(method string, err *yar.Error) bool {
+
+	if policy.Retriable != nil {
+		return policy.Retriable(method, err)
+	}
+
+	return err.Retriable()
+}
+
+// NewClient configure 在为每个地址创建 client.Client 后调用，用于设置 Opt 等参数，可以为空
+// 地址被移除后默认最多等待 10 秒关闭其连接，见 SetDrain
+func NewClient(balancer *Balancer, configure func(c *client.Client)) *Client {
+	self := new(Client)
+	self.balancer = balancer
+	self.configure = configure
+	self.clients = make(map[string]*client.Client)
+	self.drain = 10 * time.Second
+	balancer.OnRemove(self.remove)
+	return self
+}
+
+func (self *Client) Balancer() *Balancer {
+	return self.balancer
+}
+
+// Call 选择一个地址调用 method
+func (self *Client) Call(method string, ret interface{}, params ...interface{}) *yar.Error {
+	return self.CallWith(nil, method, ret, params...)
+}
+
+// SetRetry 设置失败后换地址重试的策略，传入 nil 表示不重试
+func (self *Client) SetRetry(policy *RetryPolicy) {
+	self.retry = policy
+}
+
+// SetLimiter 限制同时进行的调用数，超出时直接返回 ERR_THROTTLED 的错误，传入 nil 表示不限制
+func (self *Client) SetLimiter(limiter *Limiter) {
+	self.limiter = limiter
+}
+
+// CallWith 按 request 选择地址调用 method，如通过 Request.Key 选择一致性哈希的地址
+func (self *Client) CallWith(request *Request, method string, ret interface{}, params ...interface{}) *yar.Error {
+
+	if limiter := self.limiter; limiter != nil {
+
+		release, ok := limiter.Acquire()
+
+		if !ok {
+			return yar.NewStatusError(yar.ERR_THROTTLED, "local concurrency limit exceeded")
+		}
+
+		err := self.retryCall(request, method, ret, params)
+		release(err)
+		return err
+	}
+
+	return self.retryCall(request, method, ret, params)
+}
+
+func (self *Client) retryCall(request *Request, method string, ret interface{}, params []interface{}) *yar.Error {
+
+	policy := self.retry
+
+	if policy == nil {
+		_, err := self.call(request, method, ret, params)
+		return err
+	}
+
+	//复制一份，重试时追加已经尝试过的地址
+	attempt := new(Request)
+
+	if request != nil {
+		*attempt = *request
+		attempt.Exclude = append([]string(nil), request.Exclude...)
+	}
+
+	if policy.Budget > 0 {
+		deadline := time.Now().Add(policy.Budget)
+		if attempt.Deadline.IsZero() || deadline.Before(attempt.Deadline) {
+			attempt.Deadline = deadline
+		}
+	}
+
+	var err *yar.Error
+
+	for i := 0; i < policy.Attempts || i == 0; i++ {
+
+		var addr string
+		addr, err = self.call(attempt, method, ret, params)
+
+		if err == nil || len(addr) < 1 || !policy.retriable(method, err) {
+			return err
+		}
+
+		if !attempt.Deadline.IsZero() && !time.Now().Before(attempt.Deadline) {
+			return err
+		}
+
+		attempt.Exclude = append(attempt.Exclude, addr)
+	}
+
+	return err
+}
+
+// 选择一个地址调用一次，返回调用的地址，没有可用的地址时地址为空
+func (self *Client) call(request *Request, method string, ret interface{}, params []interface{}) (string, *yar.Error) {
+
+	if self.hedge > 0 {
+		return self.hedgedCall(request, method, ret, params)
+	}
+
+	endpoint, done, err := self.balancer.Pick(request)
+
+	if err != nil {
+		return "", yar.NewError(yar.ErrorNetwork, err.Error())
+	}
+
+	return endpoint.Host.Addr, self.invoke(endpoint, done, request.deadline(), method, ret, params)
+}
+
+// deadline 不为零时调用的超时不超过该时间
+func (self *Client) invoke(endpoint *Endpoint, done func(err *yar.Error), deadline time.Time, method string, ret interface{}, params []interface{}) *yar.Error {
+
+	c, err := self.client(endpoint.Host.Addr)
+
+	if err == nil {
+		err = do(c, deadline, method, ret, params)
+	}
+
+	done(err)
+	return err
+}
+
+func do(c *client.Client, deadline time.Time, method string, ret interface{}, params []interface{}) *yar.Error {
+
+	r, err := c.NewRequest(method, params...)
+
+	if err != nil {
+		return err
+	}
+
+	r.Deadline = deadline
+	response, err := c.Do(r, ret)
+	yar.ReleaseResponse(response)
+	yar.ReleaseRequest(r)
+	return err
+}
+
+// 取得地址对应的 client.Client，没有时创建
+func (self *Client) client(addr string) (*client.Client, *yar.Error) {
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if c, ok := self.clients[addr]; ok {
+		return c, nil
+	}
+
+	c, err := client.NewClient(addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if self.configure != nil {
+		self.configure(c)
+	}
+
+	self.clients[addr] = c
+	return c, nil
+}
@@ -0,0 +1,181 @@
+package balancer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/client"
+	"github.com/weixinhost/yar.go/metrics/metricstest"
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+type nameService struct {
+	name  string
+	delay time.Duration
+}
+
+func (s *nameService) Name() string {
+	time.Sleep(s.delay)
+	return s.name
+}
+
+// 在进程内启动名为 name 的服务，返回其地址
+func serveLoopback(t *testing.T, name string) string {
+	return serveSlowLoopback(t, name, 0)
+}
+
+func serveSlowLoopback(t *testing.T, name string, delay time.Duration) string {
+
+	loopback := transports.NewLoopback(name)
+	t.Cleanup(func() { loopback.Close() })
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&nameService{name: name, delay: delay})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	return "loopback://" + name
+}
+
+func TestClient(t *testing.T) {
+
+	hosts := NewStatic(serveLoopback(t, "balancer-a"), serveLoopback(t, "balancer-b"))
+	c := NewClient(New(NewRoundRobin(), hosts), nil)
+	calls := make(map[string]int)
+
+	for i := 0; i < 4; i++ {
+		var ret string
+		if err := c.Call("Name", &ret); err != nil {
+			t.Fatal(err)
+		}
+		calls[ret]++
+	}
+
+	if calls["balancer-a"] != 2 || calls["balancer-b"] != 2 {
+		t.Fatal(calls)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+
+	//第一个地址没有服务，重试时换到另一个地址
+	hosts := NewStatic("tcp://127.0.0.1:1", serveLoopback(t, "balancer-retry"))
+	c := NewClient(New(NewRoundRobin(), hosts), nil)
+	c.SetRetry(NewRetryPolicy())
+
+	for i := 0; i < 4; i++ {
+		var ret string
+		if err := c.Call("Name", &ret); err != nil || ret != "balancer-retry" {
+			t.Fatal(ret, err)
+		}
+	}
+
+	c.SetRetry(nil)
+	failed := 0
+
+	for i := 0; i < 4; i++ {
+		var ret string
+		if c.Call("Name", &ret) != nil {
+			failed++
+		}
+	}
+
+	if failed != 2 {
+		t.Fatal("unexpected failures without retry", failed)
+	}
+}
+
+func TestClientHedge(t *testing.T) {
+
+	hosts := NewStatic(serveSlowLoopback(t, "balancer-slow", 300*time.Millisecond), serveLoopback(t, "balancer-fast"))
+	c := NewClient(New(NewRoundRobin(), hosts), nil)
+	c.SetHedge(20 * time.Millisecond)
+
+	recorder, restore := metricstest.Install()
+	defer restore()
+
+	//原请求发往较慢的地址时，备份请求先返回
+	for i := 0; i < 4; i++ {
+		var ret string
+		start := time.Now()
+		if err := c.Call("Name", &ret); err != nil || ret != "balancer-fast" {
+			t.Fatal(ret, err)
+		}
+		if time.Since(start) > 200*time.Millisecond {
+			t.Fatal("hedged call waited for the slow host")
+		}
+	}
+
+	//原请求发往较慢的地址的调用都发出了备份请求，且由备份请求返回
+	labels := map[string]string{"method": "Name"}
+	hedged := recorder.Sum("yar.balancer.hedge", labels)
+
+	if hedged < 2 || recorder.Sum("yar.balancer.hedge.wins", labels) != hedged {
+		t.Fatal(recorder.Records("yar.balancer.hedge", nil), recorder.Records("yar.balancer.hedge.wins", nil))
+	}
+}
+
+func TestClientRetryBudget(t *testing.T) {
+
+	hosts := NewStatic(serveSlowLoopback(t, "balancer-budget-a", 500*time.Millisecond), serveSlowLoopback(t, "balancer-budget-b", 500*time.Millisecond))
+	c := NewClient(New(NewRoundRobin(), hosts), func(c *client.Client) {
+		c.Opt.Timeout = 1000
+	})
+
+	policy := NewRetryPolicy()
+	policy.Budget = 200 * time.Millisecond
+	c.SetRetry(policy)
+
+	//每次调用的超时不超过剩余的时间，两个地址都较慢时在总时间内返回超时
+	var ret string
+	start := time.Now()
+
+	if err := c.Call("Name", &ret); err == nil {
+		t.Fatal("expected timeout", ret)
+	}
+
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatal("retries exceeded the budget", elapsed)
+	}
+}
+
+func TestClientDrain(t *testing.T) {
+
+	slow := serveSlowLoopback(t, "balancer-drain", 200*time.Millisecond)
+	hosts := NewStatic(slow)
+	b := New(NewRoundRobin(), SourceFunc(func() ([]*Host, error) { return hosts, nil }))
+	b.SetRefresh(0)
+	b.Refresh()
+	c := NewClient(b, nil)
+	c.SetDrain(time.Second)
+
+	result := make(chan string, 1)
+
+	go func() {
+		var ret string
+		c.Call("Name", &ret)
+		result <- ret
+	}()
+
+	//调用进行中时地址被移除，调用正常结束后才关闭连接
+	time.Sleep(50 * time.Millisecond)
+	hosts = nil
+	b.Refresh()
+
+	if ret := <-result; ret != "balancer-drain" {
+		t.Fatal("in-flight call failed after removal", ret)
+	}
+
+	for i := 0; i < 20; i++ {
+		c.lock.Lock()
+		n := len(c.clients)
+		c.lock.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(2 * drainInterval)
+	}
+	t.Fatal("client of the removed host is not closed")
+}
@@ -0,0 +1,50 @@
+package balancer
+
+import "time"
+
+// 等待被移除的地址上的调用结束时检查的间隔
+const drainInterval = 50 * time.Millisecond
+
+// SetDrain 地址被移除后最多等待 timeout，其上的调用结束后关闭连接，超时后强制关闭，为 0 表示立即关闭
+// 被移除的地址不再被选择，等待期间地址重新加入列表时继续使用原来的连接
+func (self *Client) SetDrain(timeout time.Duration) {
+	self.lock.Lock()
+	self.drain = timeout
+	self.lock.Unlock()
+}
+
+func (self *Client) remove(endpoint *Endpoint) {
+
+	self.lock.Lock()
+	_, ok := self.clients[endpoint.Host.Addr]
+	timeout := self.drain
+	self.lock.Unlock()
+
+	if ok {
+		go self.drainEndpoint(endpoint, timeout)
+	}
+}
+
+func (self *Client) drainEndpoint(endpoint *Endpoint, timeout time.Duration) {
+
+	deadline := time.Now().Add(timeout)
+
+	for endpoint.Inflight() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainInterval)
+	}
+
+	addr := endpoint.Host.Addr
+
+	if self.balancer.contains(addr) {
+		return
+	}
+
+	self.lock.Lock()
+	c, ok := self.clients[addr]
+	delete(self.clients, addr)
+	self.lock.Unlock()
+
+	if ok {
+		c.Close()
+	}
+}
@@ -0,0 +1,83 @@
+package balancer
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 延迟估计值衰减的时间常数，没有新的调用时估计值每 10 秒衰减为原来的 1/e
+const latencyDecay = 10 * time.Second
+
+// peakEWMA 调用耗时的指数加权平均，耗时超过当前估计值时直接取该耗时，较慢的地址能尽快被发现
+type peakEWMA struct {
+	lock  sync.Mutex
+	value float64
+	stamp time.Time
+}
+
+func (ewma *peakEWMA) observe(rtt time.Duration, now time.Time) {
+
+	ewma.lock.Lock()
+	defer ewma.lock.Unlock()
+
+	if ewma.stamp.IsZero() || float64(rtt) > ewma.value {
+		ewma.value = float64(rtt)
+	} else {
+		w := math.Exp(-float64(now.Sub(ewma.stamp)) / float64(latencyDecay))
+		ewma.value = ewma.value*w + float64(rtt)*(1-w)
+	}
+
+	ewma.stamp = now
+}
+
+// 长时间没有调用的地址估计值逐渐降低，重新获得调用的机会
+func (ewma *peakEWMA) estimate(now time.Time) float64 {
+
+	ewma.lock.Lock()
+	defer ewma.lock.Unlock()
+
+	if ewma.stamp.IsZero() {
+		return 0
+	}
+
+	return ewma.value * math.Exp(-float64(now.Sub(ewma.stamp))/float64(latencyDecay))
+}
+
+// Latency 最近调用耗时的估计值，没有调用过时为 0
+func (endpoint *Endpoint) Latency() time.Duration {
+	return time.Duration(endpoint.latency.estimate(time.Now()))
+}
+
+// NewPeakEWMA 选择延迟最低的地址，延迟按 Endpoint.Latency 乘以正在进行的调用数加 1 计算
+// 每次随机取两个地址比较(power of two choices)，避免所有客户端同时涌向同一个地址
+// 适合各地址处理能力不同的场景，如机器配置不一的 PHP 服务
+func NewPeakEWMA() Strategy {
+	return StrategyFunc(func(endpoints []*Endpoint, request *Request) *Endpoint {
+
+		if len(endpoints) == 1 {
+			return endpoints[0]
+		}
+
+		i := rand.Intn(len(endpoints))
+		j := rand.Intn(len(endpoints) - 1)
+
+		if j >= i {
+			j++
+		}
+
+		now := time.Now()
+		a, b := endpoints[i], endpoints[j]
+
+		if cost(b, now) < cost(a, now) {
+			return b
+		}
+
+		return a
+	})
+}
+
+func cost(endpoint *Endpoint, now time.Time) float64 {
+	return endpoint.latency.estimate(now) * float64(endpoint.Inflight()+1)
+}
@@ -0,0 +1,158 @@
+package balancer
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type consistentHash struct {
+	replicas   int
+	loadFactor float64
+	lock       sync.Mutex
+	signature  string
+	ring       []uint64
+	owners     []string
+}
+
+// NewConsistentHash 按 Request.Key 的一致性哈希选择地址，地址增减时只有少量的键改变对应的地址
+// 每个地址有 replicas*Weight 个虚拟节点；loadFactor 大于 1 时限制每个地址正在进行的调用数
+// 不超过平均值的 loadFactor 倍，超出时顺延到哈希环上的下一个地址(bounded-load)，为 0 表示不限制
+// Key 为空时随机选择
+func NewConsistentHash(replicas int, loadFactor float64) Strategy {
+
+	if replicas <= 0 {
+		replicas = 100
+	}
+
+	strategy := new(consistentHash)
+	strategy.replicas = replicas
+	strategy.loadFactor = loadFactor
+	return strategy
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+func (strategy *consistentHash) Pick(endpoints []*Endpoint, request *Request) *Endpoint {
+
+	if request == nil || len(request.Key) < 1 {
+		return endpoints[rand.Intn(len(endpoints))]
+	}
+
+	byAddr := make(map[string]*Endpoint, len(endpoints))
+	var total int64
+
+	for _, endpoint := range endpoints {
+		byAddr[endpoint.Host.Addr] = endpoint
+		total += endpoint.Inflight()
+	}
+
+	strategy.lock.Lock()
+	ring, owners := strategy.ring, strategy.owners
+	strategy.lock.Unlock()
+
+	//没有通过 Balancer 使用时按本次的地址建立哈希环
+	if len(ring) < 1 {
+		strategy.Update(endpoints)
+		strategy.lock.Lock()
+		ring, owners = strategy.ring, strategy.owners
+		strategy.lock.Unlock()
+	}
+
+	//加上本次调用后的平均值
+	limit := int64(math.MaxInt64)
+
+	if strategy.loadFactor > 1 {
+		limit = int64(math.Ceil(strategy.loadFactor * float64(total+1) / float64(len(endpoints))))
+	}
+
+	hash := hashKey(request.Key)
+	start := sort.Search(len(ring), func(i int) bool { return ring[i] >= hash })
+	var first *Endpoint
+
+	//哈希环按完整的地址列表建立，被排除或熔断的地址顺延到环上的下一个地址
+	for i := 0; i < len(ring); i++ {
+
+		endpoint, ok := byAddr[owners[(start+i)%len(ring)]]
+
+		if !ok {
+			continue
+		}
+
+		if endpoint.Inflight() < limit {
+			return endpoint
+		}
+
+		if first == nil {
+			first = endpoint
+		}
+	}
+
+	if first == nil {
+		return endpoints[rand.Intn(len(endpoints))]
+	}
+
+	return first
+}
+
+// Update 按完整的地址列表建立哈希环，地址列表不变时复用上一次的哈希环
+func (strategy *consistentHash) Update(endpoints []*Endpoint) {
+
+	parts := make([]string, len(endpoints))
+
+	for i, endpoint := range endpoints {
+		parts[i] = endpoint.Host.Addr + "#" + strconv.Itoa(weight(endpoint.Host))
+	}
+
+	sort.Strings(parts)
+	signature := strings.Join(parts, ",")
+
+	strategy.lock.Lock()
+	defer strategy.lock.Unlock()
+
+	if signature == strategy.signature {
+		return
+	}
+
+	type node struct {
+		hash  uint64
+		owner string
+	}
+
+	var nodes []node
+
+	for _, endpoint := range endpoints {
+		for i := 0; i < strategy.replicas*weight(endpoint.Host); i++ {
+			nodes = append(nodes, node{hashKey(endpoint.Host.Addr + "-" + strconv.Itoa(i)), endpoint.Host.Addr})
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].hash < nodes[j].hash })
+
+	ring := make([]uint64, len(nodes))
+	owners := make([]string, len(nodes))
+
+	for i, n := range nodes {
+		ring[i] = n.hash
+		owners[i] = n.owner
+	}
+
+	strategy.signature = signature
+	strategy.ring = ring
+	strategy.owners = owners
+}
+
+func weight(host *Host) int {
+	if host.Weight <= 0 {
+		return 1
+	}
+	return host.Weight
+}
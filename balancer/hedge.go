@@ -0,0 +1,104 @@
+package balancer
+
+import (
+	"reflect"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+// 上报的指标：
+// yar.balancer.hedge       发出的备份请求数
+// yar.balancer.hedge.wins  备份请求先于原请求成功的次数
+// 标签 method 为调用的方法
+
+// SetHedge 调用超过 delay 没有返回时向另一个地址发出相同的备份请求，采用先成功的结果，为 0 表示关闭
+// 只应当用于幂等的方法；client.Client 的调用不能中途取消，未采用的调用在后台继续到结束，结果被丢弃
+func (self *Client) SetHedge(delay time.Duration) {
+	self.hedge = delay
+}
+
+type hedgeResult struct {
+	addr   string
+	value  reflect.Value
+	err    *yar.Error
+	backup bool
+}
+
+func (self *Client) hedgedCall(request *Request, method string, ret interface{}, params []interface{}) (string, *yar.Error) {
+
+	primary, done, err := self.balancer.Pick(request)
+
+	if err != nil {
+		return "", yar.NewError(yar.ErrorNetwork, err.Error())
+	}
+
+	//每个请求解包到单独的值中，采用的结果再复制到 ret
+	target := reflect.ValueOf(ret)
+
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return primary.Host.Addr, self.invoke(primary, done, request.deadline(), method, ret, params)
+	}
+
+	results := make(chan hedgeResult, 2)
+
+	start := func(endpoint *Endpoint, done func(err *yar.Error), backup bool) {
+		value := reflect.New(target.Type().Elem())
+		go func() {
+			err := self.invoke(endpoint, done, request.deadline(), method, value.Interface(), params)
+			results <- hedgeResult{addr: endpoint.Host.Addr, value: value, err: err, backup: backup}
+		}()
+	}
+
+	start(primary, done, false)
+	pending := 1
+
+	timer := time.NewTimer(self.hedge)
+	defer timer.Stop()
+
+	labels := map[string]string{"method": method}
+	var last hedgeResult
+
+	for pending > 0 {
+
+		select {
+		case <-timer.C:
+
+			//备份请求发往另一个可用的地址，没有时只等待原请求
+			backup := new(Request)
+			if request != nil {
+				*backup = *request
+			}
+			backup.Exclude = append(append([]string(nil), backup.Exclude...), primary.Host.Addr)
+
+			if endpoint, done, err := self.balancer.Pick(backup); err == nil {
+				metrics.Counter("yar.balancer.hedge", 1, labels)
+				start(endpoint, done, true)
+				pending++
+			}
+
+		case result := <-results:
+
+			pending--
+			last = result
+
+			if result.err != nil {
+				//原请求先失败时不再等待发出备份请求
+				if pending == 0 {
+					return result.addr, result.err
+				}
+				continue
+			}
+
+			if result.backup {
+				metrics.Counter("yar.balancer.hedge.wins", 1, labels)
+			}
+
+			target.Elem().Set(result.value.Elem())
+			return result.addr, nil
+		}
+	}
+
+	return last.addr, last.err
+}
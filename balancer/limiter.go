@@ -0,0 +1,119 @@
+package balancer
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+)
+
+// Limiter 自适应的并发限制(gradient)，按调用耗时的变化调整允许同时进行的调用数
+// 耗时相对长期平均值升高时说明对端开始排队，降低限制；耗时平稳时逐渐放宽限制
+type Limiter struct {
+	lock     sync.Mutex
+	limit    float64
+	min      float64
+	max      float64
+	inflight int
+	//longRTT 耗时的长期平均值
+	longRTT float64
+}
+
+// NewLimiter initial 为初始的并发限制，限制在 min 与 max 之间调整
+// 限制只在调用结束时调整，min 至少为 1，否则限制降为 0 后不能再恢复；initial 超出范围时取 min 或 max
+func NewLimiter(initial int, min int, max int) *Limiter {
+
+	if min < 1 {
+		min = 1
+	}
+
+	if max < min {
+		max = min
+	}
+
+	if initial < min {
+		initial = min
+	}
+
+	if initial > max {
+		initial = max
+	}
+
+	limiter := new(Limiter)
+	limiter.limit = float64(initial)
+	limiter.min = float64(min)
+	limiter.max = float64(max)
+	return limiter
+}
+
+// Limit 当前的并发限制
+func (limiter *Limiter) Limit() int {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	return int(limiter.limit)
+}
+
+// Inflight 正在进行的调用数
+func (limiter *Limiter) Inflight() int {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	return limiter.inflight
+}
+
+// Acquire 正在进行的调用数未达到限制时返回 true，调用结束后必须调用 release
+func (limiter *Limiter) Acquire() (func(err *yar.Error), bool) {
+
+	limiter.lock.Lock()
+
+	if float64(limiter.inflight) >= math.Floor(limiter.limit) {
+		limiter.lock.Unlock()
+		return nil, false
+	}
+
+	limiter.inflight++
+	inflight := limiter.inflight
+	limiter.lock.Unlock()
+
+	start := time.Now()
+	var once sync.Once
+
+	return func(err *yar.Error) {
+		once.Do(func() {
+			limiter.observe(float64(time.Since(start)), inflight, err)
+		})
+	}, true
+}
+
+func (limiter *Limiter) observe(rtt float64, inflight int, err *yar.Error) {
+
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	limiter.inflight--
+
+	if limiter.longRTT == 0 {
+		limiter.longRTT = rtt
+	}
+
+	gradient := 1.0
+
+	if err != nil && err.Retriable() {
+		//超时、限流等错误按耗时翻倍处理
+		gradient = 0.5
+	} else {
+		limiter.longRTT = limiter.longRTT*0.95 + rtt*0.05
+		gradient = math.Max(0.5, math.Min(1, limiter.longRTT/rtt))
+	}
+
+	//调用数远低于限制时耗时不能说明限制是否合适，不放宽限制
+	queue := math.Sqrt(limiter.limit)
+
+	if float64(inflight) < limiter.limit/2 {
+		queue = 0
+	}
+
+	next := limiter.limit*gradient + queue
+	limiter.limit = limiter.limit*0.8 + next*0.2
+	limiter.limit = math.Max(limiter.min, math.Min(limiter.max, limiter.limit))
+}
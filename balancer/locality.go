@@ -0,0 +1,44 @@
+package balancer
+
+import "math/rand"
+
+// SetLocality 优先调用 Host.Zone 与 zone 相同的地址，减少跨可用区的流量，zone 为空表示关闭
+// 本可用区可用(未熔断)的地址占比低于 healthy 时，按不足的比例把调用分流到其它可用区
+// 如 healthy 为 0.7 时，本可用区只有一半的地址可用，约 1-0.5/0.7 即 29% 的调用发往其它可用区
+func (balancer *Balancer) SetLocality(zone string, healthy float64) {
+
+	if healthy <= 0 || healthy > 1 {
+		healthy = 1
+	}
+
+	balancer.lock.Lock()
+	balancer.zone = zone
+	balancer.healthy = healthy
+	balancer.lock.Unlock()
+}
+
+// 按本可用区可用地址的比例选择本可用区或其它可用区的候选地址，total 为本可用区参与选择的地址数
+func localized(candidates []*Endpoint, zone string, total int, healthy float64) []*Endpoint {
+
+	var local, remote []*Endpoint
+
+	for _, endpoint := range candidates {
+		if endpoint.Host.Zone == zone {
+			local = append(local, endpoint)
+		} else {
+			remote = append(remote, endpoint)
+		}
+	}
+
+	if len(local) < 1 || len(remote) < 1 {
+		return candidates
+	}
+
+	share := float64(len(local)) / float64(total) / healthy
+
+	if share >= 1 || rand.Float64() < share {
+		return local
+	}
+
+	return remote
+}
@@ -0,0 +1,45 @@
+package balancer
+
+import (
+	"context"
+
+	"github.com/weixinhost/yar.go/client"
+)
+
+// FromSRV 使用 SRV 记录中优先级最高的一组地址，scheme 为 tcp、http 等，Weight 为记录的权重
+func FromSRV(source *client.SRVSource, scheme string) Source {
+	return SourceFunc(func() ([]*Host, error) {
+
+		targets, err := source.Targets(context.Background())
+
+		if len(targets) < 1 {
+			return nil, err
+		}
+
+		var hosts []*Host
+
+		for _, target := range targets {
+			if target.Priority != targets[0].Priority {
+				break
+			}
+			hosts = append(hosts, &Host{Addr: scheme + "://" + target.Address, Weight: int(target.Weight)})
+		}
+
+		return hosts, nil
+	})
+}
+
+// FromHostFile 使用文件中 pool/service 的地址列表，scheme 为 tcp、http 等
+func FromHostFile(file *client.HostFile, pool string, service string, scheme string) Source {
+	return SourceFunc(func() ([]*Host, error) {
+
+		addresses := file.Hosts(pool, service)
+		hosts := make([]*Host, len(addresses))
+
+		for i, address := range addresses {
+			hosts[i] = &Host{Addr: scheme + "://" + address, Weight: 1}
+		}
+
+		return hosts, nil
+	})
+}
@@ -0,0 +1,93 @@
+package balancer
+
+import (
+	"sync"
+	"time"
+)
+
+type pin struct {
+	addr    string
+	expired time.Time
+}
+
+type sticky struct {
+	fallback Strategy
+	ttl      time.Duration
+	lock     sync.Mutex
+	pins     map[string]pin
+	swept    time.Time
+}
+
+// NewSticky 同一 Request.Session 的调用选择同一地址，适合在服务端保存会话状态的场景
+// 会话超过 ttl 没有调用，或者对应的地址已不在列表中时，通过 fallback 重新选择并绑定
+// Session 为空时直接使用 fallback，fallback 为空时使用 RoundRobin
+func NewSticky(fallback Strategy, ttl time.Duration) Strategy {
+
+	if fallback == nil {
+		fallback = NewRoundRobin()
+	}
+
+	strategy := new(sticky)
+	strategy.fallback = fallback
+	strategy.ttl = ttl
+	strategy.pins = make(map[string]pin)
+	strategy.swept = time.Now()
+	return strategy
+}
+
+func (strategy *sticky) Pick(endpoints []*Endpoint, request *Request) *Endpoint {
+
+	if request == nil || len(request.Session) < 1 {
+		return strategy.fallback.Pick(endpoints, request)
+	}
+
+	now := time.Now()
+
+	strategy.lock.Lock()
+	pinned, ok := strategy.pins[request.Session]
+	strategy.lock.Unlock()
+
+	if ok && now.Before(pinned.expired) {
+		for _, endpoint := range endpoints {
+			if endpoint.Host.Addr == pinned.addr {
+				strategy.bind(request.Session, pinned.addr, now)
+				return endpoint
+			}
+		}
+	}
+
+	endpoint := strategy.fallback.Pick(endpoints, request)
+
+	if endpoint != nil {
+		strategy.bind(request.Session, endpoint.Host.Addr, now)
+	}
+
+	return endpoint
+}
+
+func (strategy *sticky) Update(endpoints []*Endpoint) {
+	if updater, ok := strategy.fallback.(Updater); ok {
+		updater.Update(endpoints)
+	}
+}
+
+func (strategy *sticky) bind(session string, addr string, now time.Time) {
+
+	strategy.lock.Lock()
+	defer strategy.lock.Unlock()
+
+	strategy.pins[session] = pin{addr: addr, expired: now.Add(strategy.ttl)}
+
+	//每隔 ttl 清理一次过期的会话
+	if now.Sub(strategy.swept) < strategy.ttl {
+		return
+	}
+
+	for key, p := range strategy.pins {
+		if now.After(p.expired) {
+			delete(strategy.pins, key)
+		}
+	}
+
+	strategy.swept = now
+}
@@ -0,0 +1,117 @@
+package balancer
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Strategy 从可选的地址中选择一个，endpoints 不为空，返回 nil 表示没有可用的地址
+// Pick 会被并发调用
+type Strategy interface {
+	Pick(endpoints []*Endpoint, request *Request) *Endpoint
+}
+
+// Updater Strategy 可以实现的接口，地址列表刷新后以完整的地址列表调用
+// Pick 只收到排除、熔断等过滤之后的地址，一致性哈希等需要按完整列表建立状态的策略通过该接口取得
+type Updater interface {
+	Update(endpoints []*Endpoint)
+}
+
+// StrategyFunc 将函数作为 Strategy 使用
+type StrategyFunc func(endpoints []*Endpoint, request *Request) *Endpoint
+
+func (f StrategyFunc) Pick(endpoints []*Endpoint, request *Request) *Endpoint {
+	return f(endpoints, request)
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+// NewRoundRobin 依次选择每个地址
+func NewRoundRobin() Strategy {
+	return new(roundRobin)
+}
+
+func (strategy *roundRobin) Pick(endpoints []*Endpoint, request *Request) *Endpoint {
+	n := atomic.AddUint64(&strategy.next, 1)
+	return endpoints[(n-1)%uint64(len(endpoints))]
+}
+
+// NewRandom 随机选择一个地址
+func NewRandom() Strategy {
+	return StrategyFunc(func(endpoints []*Endpoint, request *Request) *Endpoint {
+		return endpoints[rand.Intn(len(endpoints))]
+	})
+}
+
+// NewLeastConnections 选择正在进行的调用数最少的地址，数量相同时随机选择
+func NewLeastConnections() Strategy {
+	return StrategyFunc(func(endpoints []*Endpoint, request *Request) *Endpoint {
+
+		var best *Endpoint
+		var least int64
+		ties := 0
+
+		for _, endpoint := range endpoints {
+
+			inflight := endpoint.Inflight()
+
+			switch {
+			case best == nil || inflight < least:
+				best, least, ties = endpoint, inflight, 1
+			case inflight == least:
+				//蓄水池抽样，相同数量的地址被选中的概率相同
+				ties++
+				if rand.Intn(ties) == 0 {
+					best = endpoint
+				}
+			}
+		}
+
+		return best
+	})
+}
+
+type weightedRoundRobin struct {
+	lock    sync.Mutex
+	current map[string]int
+}
+
+// NewWeightedRoundRobin 按 Host.Weight 的比例依次选择地址(平滑加权轮询)，权重高的地址不会被连续集中选择
+func NewWeightedRoundRobin() Strategy {
+	strategy := new(weightedRoundRobin)
+	strategy.current = make(map[string]int)
+	return strategy
+}
+
+func (strategy *weightedRoundRobin) Pick(endpoints []*Endpoint, request *Request) *Endpoint {
+
+	strategy.lock.Lock()
+	defer strategy.lock.Unlock()
+
+	//地址列表变化后清理已经不存在的地址
+	if len(strategy.current) > 2*len(endpoints) {
+		current := make(map[string]int, len(endpoints))
+		for _, endpoint := range endpoints {
+			current[endpoint.Host.Addr] = strategy.current[endpoint.Host.Addr]
+		}
+		strategy.current = current
+	}
+
+	var best *Endpoint
+	total := 0
+
+	for _, endpoint := range endpoints {
+		w := weight(endpoint.Host)
+		total += w
+		strategy.current[endpoint.Host.Addr] += w
+		if best == nil || strategy.current[endpoint.Host.Addr] > strategy.current[best.Host.Addr] {
+			best = endpoint
+		}
+	}
+
+	strategy.current[best.Host.Addr] -= total
+	return best
+}
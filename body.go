@@ -0,0 +1,125 @@
+package yar
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/weixinhost/yar.go/compress"
+)
+
+const checksumLength = 4
+
+// EncodeBody 对打包后的数据按照头部标志依次进行压缩、加密、追加校验值等处理
+func EncodeBody(header *Header, opt *Opt, data []byte) ([]byte, *Error) {
+
+	data, err := compressBody(header, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Encrypt == 1 {
+
+		encrypted, encryptErr := encryptBody(header, opt, data)
+
+		if encryptErr != nil {
+			return nil, NewError(ErrorEncrypt, "encrypt error:"+encryptErr.Error())
+		}
+
+		data = encrypted
+	}
+
+	if header.HasFlag(FlagChecksum) {
+		var sum [checksumLength]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(data))
+		data = append(data, sum[:]...)
+	}
+
+	return data, nil
+}
+
+// DecodeBody 根据头部标志还原打包后的数据
+func DecodeBody(header *Header, opt *Opt, data []byte) ([]byte, *Error) {
+
+	if header.HasFlag(FlagChecksum) {
+
+		if len(data) < checksumLength {
+			return nil, NewError(ErrorProtocol, "body too short for checksum")
+		}
+
+		n := len(data) - checksumLength
+		sum := binary.BigEndian.Uint32(data[n:])
+		data = data[:n]
+
+		if crc32.ChecksumIEEE(data) != sum {
+			return nil, NewError(ErrorProtocol, "body checksum mismatch")
+		}
+	}
+
+	if header.Encrypt == 1 {
+
+		decrypted, err := decryptBody(header, opt, data)
+
+		if err != nil {
+			return nil, NewError(ErrorEncrypt, "decrypt error:"+err.Error())
+		}
+
+		data = decrypted
+	}
+
+	return decompressBody(header, data)
+}
+
+func compressBody(header *Header, data []byte) ([]byte, *Error) {
+
+	id := header.Compression()
+
+	if id == compress.None {
+		return data, nil
+	}
+
+	c, ok := compress.Get(id)
+
+	if !ok {
+		return nil, NewError(ErrorConfig, "unsupported compression:"+compress.Name(id))
+	}
+
+	compressed, err := c.Compress(data)
+
+	if err != nil {
+		return nil, NewError(ErrorPackager, "compress error:"+err.Error())
+	}
+
+	return compressed, nil
+}
+
+func decompressBody(header *Header, data []byte) ([]byte, *Error) {
+
+	id := header.Compression()
+
+	if id == compress.None {
+		return data, nil
+	}
+
+	c, ok := compress.Get(id)
+
+	if !ok {
+		return nil, NewError(ErrorProtocol, "unsupported compression:"+compress.Name(id))
+	}
+
+	var decompressed []byte
+	var err error
+
+	//解压后的数据同样不超过 MaxBodyLength
+	if limited, ok := c.(compress.LimitedDecompressor); ok {
+		decompressed, err = limited.DecompressLimit(data, int(MaxBodyLength))
+	} else {
+		decompressed, err = c.Decompress(data)
+	}
+
+	if err != nil {
+		return nil, NewError(ErrorPackager, "decompress error:"+err.Error())
+	}
+
+	return decompressed, nil
+}
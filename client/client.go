@@ -2,25 +2,43 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
-	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/compress"
 	"github.com/weixinhost/yar.go/packager"
 	"github.com/weixinhost/yar.go/transports"
 )
 
 type Client struct {
-	hostname  string
-	net       string
-	transport transports.Transport
-	Opt       *yar.Opt
+	hostname    string
+	net         string
+	transport   transports.Transport
+	validators  map[string]*validator
+	dialer      transports.DialContextFunc
+	resolver    Resolver
+	peerVersion int32
+	muxState    int32
+	muxLock     sync.Mutex
+	mux         *muxConn
+	Metadata    yar.Metadata
+	Opt         *yar.Opt
+}
+
+type validator struct {
+	request  packager.ValidateFunc
+	response packager.ValidateFunc
 }
 
 // 获取一个YAR 客户端
@@ -28,7 +46,12 @@ type Client struct {
 // http://xxxxxxxx
 // https://xxxx.xx.xx
 // tcp://xxxx
+// tls://xxxx 即 tcp 之上的 TLS
 // udp://xxxx
+// unix:///path/to/sock
+// unixgram:///path/to/sock 数据报，只能通过 Notify 发送不需要返回的请求
+// kcp://xxxx 需先通过 transports.RegisterKCP 注册 KCP 的实现
+// 其它 scheme 使用 transports.Register 注册的传输
 func NewClient(addr string) (*Client, *yar.Error) {
 	netName, err := parseAddrNetName(addr)
 	if err != nil {
@@ -40,34 +63,352 @@ func NewClient(addr string) (*Client, *yar.Error) {
 	client.hostname = addr
 	client.net = netName
 	client.Opt = yar.NewOpt()
-	client.init()
+	client.validators = make(map[string]*validator)
+	//对端协议版本，-1 表示尚未得知
+	client.peerVersion = -1
+	//Metadata 附加到该客户端发出的每个请求中
+	client.Metadata = nil
+
+	if err := client.init(); err != nil {
+		return nil, yar.NewError(yar.ErrorConfig, err.Error())
+	}
+
 	return client, nil
 }
 
-func (client *Client) init() {
+func (client *Client) init() error {
 	switch client.net {
-	case "tcp", "udp", "unix":
+	case "http", "https":
 		{
-			client.transport, _ = transports.NewSock(client.net, client.hostname)
+			httpClient, _ := transports.NewHttpClient(client.hostname)
+			httpClient.SetDialer(client.dialResolved)
+			client.transport = httpClient
+			break
+		}
+	case "tls":
+		{
+			address := strings.TrimPrefix(client.hostname, client.net+"://")
+			sock, _ := transports.NewSock("tcp", address)
+			sock.SetTLSConfig(new(tls.Config))
+			sock.SetDialer(client.dialResolved)
+			sock.SetPool(transports.NewPoolConfig())
+			client.transport = sock
+			break
+		}
+	case "tcp", "udp", "unix", "unixgram":
+		{
+			address := strings.TrimPrefix(client.hostname, client.net+"://")
+			sock, _ := transports.NewSock(client.net, address)
+			sock.SetDialer(client.dialResolved)
+			//开启 Opt.Persistent 时连接在池中复用
+			if client.net == "tcp" || client.net == "unix" {
+				sock.SetPool(transports.NewPoolConfig())
+			}
+			client.transport = sock
+			break
+		}
+	default:
+		{
+			factory, ok := transports.Lookup(client.net)
+			if !ok {
+				return errors.New("unsupport net protocol: " + client.net)
+			}
+			transport, err := factory(strings.TrimPrefix(client.hostname, client.net+"://"))
+			if err != nil {
+				return err
+			}
+			client.transport = transport
 			break
 		}
 	}
 
+	return nil
+}
+
+// 建立 tcp 连接时通过 SetResolver 设置的解析，或开启 Opt.DNSCache 时通过 DefaultResolver 解析域名
+// 解析到多个地址时并行连接
+func (client *Client) dialResolved(ctx context.Context, network string, address string) (net.Conn, error) {
+
+	resolver := client.resolver
+
+	if resolver == nil && client.Opt.DNSCache {
+		resolver = DefaultResolver
+	}
+
+	host, port, err := net.SplitHostPort(address)
+
+	if resolver == nil || err != nil || !strings.HasPrefix(network, "tcp") || net.ParseIP(host) != nil {
+		return client.dialContext(ctx, network, address)
+	}
+
+	ips, err := resolver.Lookup(ctx, host)
+	if err != nil {
+		return nil, errors.New("Lookup Error:" + err.Error())
+	}
+	if len(ips) < 1 {
+		return nil, errors.New("Lookup Error: No IP Resolver Result Found")
+	}
+	//多个地址时交替 IPv6/IPv4 依次尝试，避免固定连接到第一个不可用的地址
+	ips = interleaveIPs(ips)
+	addresses := make([]string, len(ips))
+	for i, ip := range ips {
+		addresses[i] = net.JoinHostPort(ip.String(), port)
+	}
+	return transports.DialParallel(ctx, client.dialContext, network, addresses, transports.DefaultFallbackDelay)
+}
+
+// 按 Opt.ConnectTimeout 建立连接，设置了 SetDialer 时使用自定义的函数
+func (client *Client) dialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+
+	if client.Opt.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(client.Opt.ConnectTimeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	if client.dialer != nil {
+		return client.dialer(ctx, network, address)
+	}
+
+	return transports.DefaultDialContext(ctx, network, address)
+}
+
+// SetDialer 替换建立连接的函数，如指定源地址、经过代理或使用自己的域名解析，传入 nil 恢复默认
+// 同一函数用于 http 与 tcp/unix 连接，Opt.ConnectTimeout 通过 ctx 传入
+func (client *Client) SetDialer(dial transports.DialContextFunc) {
+	client.dialer = dial
+}
+
+// SetResolver 设置 tcp 连接使用的域名解析，可以使用 NewCacheResolver 缓存结果，传入 nil 恢复 Opt.DNSCache 的行为
+func (client *Client) SetResolver(resolver Resolver) {
+	client.resolver = resolver
+}
+
+// SetPool 设置 tcp/unix 连接池的参数，传入 nil 表示不复用连接
+func (client *Client) SetPool(config *transports.PoolConfig) *yar.Error {
+
+	sock, ok := client.transport.(*transports.Sock)
+
+	if !ok {
+		return yar.NewError(yar.ErrorConfig, "connection pool is only supported on sock transports")
+	}
+
+	//设置了 KeepAlive 而没有 Ping 时使用 yar 的探测帧
+	if config != nil && config.KeepAlive > 0 && config.Ping == nil {
+		copied := *config
+		copied.Ping = client.ping
+		config = &copied
+	}
+
+	sock.SetPool(config)
+	return nil
+}
+
+// Close 关闭并发连接及连接池中的空闲连接，正在使用的连接在调用结束后关闭
+// 之后的调用不再复用连接，loopback 等共享的传输不受影响
+func (client *Client) Close() {
+
+	client.closeMux()
+
+	switch transport := client.transport.(type) {
+	case *transports.Sock:
+		transport.Close()
+	case *transports.HttpClient:
+		transport.Close()
+	}
+}
+
+// SetReconnect 设置 tcp/unix 连接失败后的重试间隔，复用的连接写入失败时重新建立连接，传入 nil 关闭重连
+func (client *Client) SetReconnect(backoff *transports.Backoff) *yar.Error {
+
+	sock, ok := client.transport.(*transports.Sock)
+
+	if !ok {
+		return yar.NewError(yar.ErrorConfig, "reconnect is only supported on sock transports")
+	}
+
+	sock.SetReconnect(backoff)
+	return nil
+}
+
+// SetProxyHeader 建立 tcp 连接后先写出 PROXY 头，用于经过 haproxy 等代理时传递真实的客户端地址
+func (client *Client) SetProxyHeader(header *transports.ProxyHeader) *yar.Error {
+
+	sock, ok := client.transport.(*transports.Sock)
+
+	if !ok || client.net == "unix" || client.net == "unixgram" || client.net == "udp" {
+		return yar.NewError(yar.ErrorConfig, "proxy protocol is only supported on tcp:// and tls:// addresses")
+	}
+
+	sock.SetProxyHeader(header)
+	return nil
+}
+
+// OnConnState 设置 tcp/unix 连接状态变化的回调
+func (client *Client) OnConnState(handler transports.ConnStateHandler) *yar.Error {
+
+	sock, ok := client.transport.(*transports.Sock)
+
+	if !ok {
+		return yar.NewError(yar.ErrorConfig, "connection state is only supported on sock transports")
+	}
+
+	sock.OnStateChange(handler)
+	return nil
+}
+
+// SetTLSConfig 设置 tls:// 与 https:// 使用的 TLS 配置，如客户端证书、CA 等，见 transports.NewTLSConfig
+func (client *Client) SetTLSConfig(config *tls.Config) *yar.Error {
+
+	switch transport := client.transport.(type) {
+	case *transports.HttpClient:
+		if client.net == "https" {
+			transport.SetTLSConfig(config)
+			return nil
+		}
+	case *transports.Sock:
+		if client.net == "tls" {
+			transport.SetTLSConfig(config)
+			return nil
+		}
+	}
+
+	return yar.NewError(yar.ErrorConfig, "tls is only supported on tls:// and https:// addresses")
+}
+
+// SetSocketOptions 设置连接的 socket 参数，见 transports.NewLatencySocketOptions 与 transports.NewBulkSocketOptions
+func (client *Client) SetSocketOptions(opt *transports.SocketOptions) *yar.Error {
+
+	switch transport := client.transport.(type) {
+	case *transports.HttpClient:
+		transport.SetSocketOptions(opt)
+	case *transports.Sock:
+		transport.SetSocketOptions(opt)
+	default:
+		return yar.NewError(yar.ErrorConfig, "socket options are not supported on this transport")
+	}
+
+	return nil
+}
+
+// SetThrottle 限制该客户端所有连接的读写速度(字节/秒)，为 0 表示不限速
+func (client *Client) SetThrottle(readRate int, writeRate int) *yar.Error {
+
+	switch transport := client.transport.(type) {
+	case *transports.HttpClient:
+		transport.SetThrottle(readRate, writeRate)
+	case *transports.Sock:
+		transport.SetThrottle(readRate, writeRate)
+	default:
+		return yar.NewError(yar.ErrorConfig, "throttling is not supported on this transport")
+	}
+
+	return nil
+}
+
+// SetServerName 设置 tls:// 与 https:// 的 SNI 及验证证书的主机名，用于以 IP 访问的服务
+// 不改变是否验证证书，https:// 默认不验证，需要验证时设置 SetRootCAs 或 SetTLSConfig
+func (client *Client) SetServerName(name string) *yar.Error {
+
+	switch transport := client.transport.(type) {
+	case *transports.HttpClient:
+		if client.net == "https" {
+			transport.SetServerName(name)
+			return nil
+		}
+	case *transports.Sock:
+		if client.net == "tls" {
+			transport.SetServerName(name)
+			return nil
+		}
+	}
+
+	return yar.NewError(yar.ErrorConfig, "tls is only supported on tls:// and https:// addresses")
+}
+
+// SetRootCAs 设置 tls:// 与 https:// 验证服务端证书使用的 CA，如内部 PKI 的根证书，设置后验证服务端证书，见 transports.LoadCertPool
+func (client *Client) SetRootCAs(pool *x509.CertPool) *yar.Error {
+
+	switch transport := client.transport.(type) {
+	case *transports.HttpClient:
+		if client.net == "https" {
+			transport.SetRootCAs(pool)
+			return nil
+		}
+	case *transports.Sock:
+		if client.net == "tls" {
+			transport.SetRootCAs(pool)
+			return nil
+		}
+	}
+
+	return yar.NewError(yar.ErrorConfig, "tls is only supported on tls:// and https:// addresses")
+}
+
+// 为方法设置校验函数
+// request 在请求打包前校验调用参数，response 在返回值解包后进行校验，传入 nil 表示不校验
+func (client *Client) SetValidator(method string, request packager.ValidateFunc, response packager.ValidateFunc) {
+	client.validators[strings.ToLower(method)] = &validator{request: request, response: response}
+}
+
+// 与对端协商后的协议版本，尚未得知对端版本时视为原始协议
+func (client *Client) version() uint16 {
+
+	peer := atomic.LoadInt32(&client.peerVersion)
+
+	if peer < 0 {
+		return yar.ProtocolVersionLegacy
+	}
+
+	return yar.NegotiateVersion(client.Opt.Version, uint16(peer))
+}
+
+func (client *Client) extensions() bool {
+	return client.version() >= yar.ProtocolVersionFlags
 }
 
 func (client *Client) Call(method string, ret interface{}, params ...interface{}) *yar.Error {
 
-	if client.net == "http" || client.net == "https" {
-		return client.httpHandler(method, ret, params...)
+	r, err := client.NewRequest(method, params...)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(r, ret)
+	yar.ReleaseResponse(response)
+	yar.ReleaseRequest(r)
+	return err
+}
+
+// Notify 发送请求后不等待返回，服务端的返回被丢弃
+// 适合 unixgram 等只发不收的场景，不保证服务端收到或处理成功
+func (client *Client) Notify(method string, params ...interface{}) *yar.Error {
+
+	r, err := client.NewRequest(method, params...)
+
+	if err != nil {
+		return err
+	}
+
+	if client.Opt.ReplayProtection {
+		r.Stamp()
 	}
 
-	return yar.NewError(yar.ErrorConfig, "unsupported non http protocol")
+	if client.transport == nil {
+		return yar.NewError(yar.ErrorConfig, "unsupported protocol:"+client.net)
+	}
 
+	err = client.send(r)
+	yar.ReleaseRequest(r)
+	return err
 }
 
-func (client *Client) initRequest(method string, params ...interface{}) (*yar.Request, *yar.Error) {
+// 创建一个请求，可以在调用 Do 之前修改请求，如：
+// r.Protocol.SetProvider("tenant") 或 r.Protocol.SetToken("token") 覆盖 client.Opt 中的设置
+func (client *Client) NewRequest(method string, params ...interface{}) (*yar.Request, *yar.Error) {
 
-	r := yar.NewRequest()
+	r := yar.AcquireRequest()
 
 	if len(method) < 1 {
 		return nil, yar.NewError(yar.ErrorParam, "call empty method")
@@ -82,144 +423,321 @@ func (client *Client) initRequest(method string, params ...interface{}) (*yar.Re
 	r.Method = method
 
 	r.Protocol.MagicNumber = client.Opt.MagicNumber
+	r.Protocol.Version = client.Opt.Version
 	r.Protocol.Id = r.Id
+	r.Protocol.SetProvider(client.Opt.Provider)
+	r.Protocol.SetToken(client.Opt.Token)
+
+	if client.Opt.Encrypt {
+		r.Protocol.Encrypt = 1
+		r.Protocol.SetKeyId(client.Opt.KeyId)
+	}
+
+	if len(client.Metadata) > 0 {
+		r.Metadata = client.Metadata.Copy()
+	}
+
 	return r, nil
 }
 
-func (client *Client) packRequest(r *yar.Request) ([]byte, *yar.Error) {
+// 发送请求，返回值解包到 ret 中
+func (client *Client) Do(r *yar.Request, ret interface{}) (*yar.Response, *yar.Error) {
+
+	if client.Opt.ReplayProtection {
+		r.Stamp()
+	}
+
+	if client.Opt.Trailer && client.extensions() {
+		r.Protocol.SetFlag(yar.FlagTrailer)
+	}
+
+	if client.transport == nil {
+		return nil, yar.NewError(yar.ErrorConfig, "unsupported protocol:"+client.net)
+	}
+
+	return client.roundTrip(r, ret)
+
+}
+
+func (client *Client) packagerName() []byte {
 
-	var sendPackager []byte
 	packagerName := client.Opt.Packager
 
 	if len(packagerName) < yar.PackagerLength {
-		sendPackager = []byte(packagerName)
-	} else {
-		sendPackager = []byte(packagerName[0:yar.PackagerLength])
+		return []byte(packagerName)
 	}
 
-	var p [8]byte
-	for i, s := range sendPackager {
-		p[i] = s
-	}
+	return []byte(packagerName[0:yar.PackagerLength])
+}
+
+func (client *Client) packRequest(r *yar.Request) ([]byte, *yar.Error) {
 
-	r.Protocol.Packager = p
-	pack, err := packager.Pack(sendPackager, r)
+	sendPackager := client.packagerName()
+	r.Protocol.SetPackager(client.Opt.Packager)
+
+	var pack []byte
+	var err error
+
+	if client.Opt.Canonical {
+		pack, err = packager.PackCanonical(sendPackager, r)
+	} else {
+		pack, err = packager.Pack(sendPackager, r)
+	}
 
 	if err != nil {
 		return nil, yar.NewError(yar.ErrorPackager, err.Error())
 	}
 
-	return pack, nil
-}
+	if len(client.Opt.Compression) > 0 && client.extensions() {
 
-func (client *Client) readResponse(reader io.Reader, ret interface{}) *yar.Error {
+		id, ok := compress.Lookup(client.Opt.Compression)
 
-	allBody, err := ioutil.ReadAll(reader)
+		if !ok {
+			return nil, yar.NewError(yar.ErrorConfig, "unsupported compression:"+client.Opt.Compression)
+		}
 
-	if err != nil {
-		return yar.NewError(yar.ErrorResponse, "Read Response Error:"+err.Error())
+		r.Protocol.SetCompression(id)
 	}
 
-	if len(allBody) < (yar.ProtocolLength + yar.PackagerLength) {
-		return yar.NewError(yar.ErrorResponse, "Response Parse Error:"+string(allBody))
+	if client.Opt.Checksum && client.extensions() {
+		r.Protocol.SetFlag(yar.FlagChecksum)
 	}
 
-	protocolBuffer := allBody[0 : yar.ProtocolLength+yar.PackagerLength]
+	body, encodeErr := yar.EncodeBody(r.Protocol, client.Opt, pack)
 
-	protocol := yar.NewHeader()
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
 
-	protocol.Init(bytes.NewBuffer(protocolBuffer))
+	if len(client.Opt.SignSecret) > 0 {
+		yar.SignHeader(r.Protocol, client.Opt.SignSecret, body)
+	}
+
+	return body, nil
+}
+
+// 以续帧模式写出请求，数据边打包边写出
+func (client *Client) writeFramedRequest(w io.Writer, r *yar.Request) error {
+
+	sendPackager := client.packagerName()
+	r.Protocol.SetPackager(client.Opt.Packager)
+
+	fw := yar.NewFrameWriter(w, r.Protocol, client.Opt.MaxFrameSize)
+
+	if client.Opt.Canonical {
+
+		pack, err := packager.PackCanonical(sendPackager, r)
 
-	bodyLength := protocol.BodyLength - yar.PackagerLength
+		if err != nil {
+			return err
+		}
+
+		if _, err = fw.Write(pack); err != nil {
+			return err
+		}
 
-	if uint32(len(allBody)-(yar.ProtocolLength+yar.PackagerLength)) < uint32(bodyLength) {
-		return yar.NewError(yar.ErrorResponse, "Response Content Error:"+string(allBody))
+	} else if err := packager.PackTo(sendPackager, fw, r); err != nil {
+		return err
 	}
 
-	bodyBuffer := allBody[yar.ProtocolLength+yar.PackagerLength:]
+	return fw.Close()
+}
+
+// Opt.Layout 指定的头部布局
+func (client *Client) layout() (*yar.Layout, *yar.Error) {
 
-	response := new(yar.Response)
-	err = packager.Unpack([]byte(client.Opt.Packager), bodyBuffer, &response)
+	layout, ok := yar.LookupLayout(client.Opt.Layout)
 
-	if err != nil {
-		return yar.NewError(yar.ErrorPackager, "Unpack Error:"+err.Error())
+	if !ok {
+		return nil, yar.NewError(yar.ErrorConfig, "unsupported header layout:"+client.Opt.Layout)
 	}
 
-	if response.Status != yar.ERR_OKEY {
-		return yar.NewError(yar.ErrorResponse, response.Error)
+	return layout, nil
+}
+
+func (client *Client) chunked() bool {
+	return client.Opt.ChunkSize > 0 && !client.Opt.Encrypt && len(client.Opt.SignSecret) < 1 && client.extensions()
+}
+
+func (client *Client) framed() bool {
+	return client.Opt.ChunkSize <= 0 && client.Opt.MaxFrameSize > 0 && !client.Opt.Encrypt && len(client.Opt.SignSecret) < 1 && client.version() >= yar.ProtocolVersionContinuation
+}
+
+// 以分块模式写出请求，数据边打包边写出
+func (client *Client) writeChunkedRequest(w io.Writer, r *yar.Request) error {
+
+	sendPackager := client.packagerName()
+	r.Protocol.SetPackager(client.Opt.Packager)
+	r.Protocol.SetFlag(yar.FlagChunked)
+	r.Protocol.BodyLength = yar.PackagerLength
+
+	if _, err := w.Write(r.Protocol.Bytes().Bytes()); err != nil {
+		return err
 	}
 
-	if ret != nil {
+	cw := packager.NewChunkWriter(w, client.Opt.ChunkSize)
 
-		packData, err := packager.Pack([]byte(client.Opt.Packager), response.Retval)
+	if client.Opt.Canonical {
+
+		pack, err := packager.PackCanonical(sendPackager, r)
 
 		if err != nil {
-			return yar.NewError(yar.ErrorPackager, "pack response retval error:"+err.Error()+" "+string(allBody))
+			return err
 		}
 
-		err = packager.Unpack([]byte(client.Opt.Packager), packData, ret)
-
-		if err != nil {
-			return yar.NewError(yar.ErrorPackager, "pack response retval error:"+err.Error()+" "+string(allBody))
+		if _, err = cw.Write(pack); err != nil {
+			return err
 		}
+
+	} else if err := packager.PackTo(sendPackager, cw, r); err != nil {
+		return err
+	}
+
+	return cw.Close()
+}
+
+func (client *Client) validateRequest(r *yar.Request) *yar.Error {
+
+	v, ok := client.validators[strings.ToLower(r.Method)]
+
+	if !ok {
+		return nil
+	}
+
+	if err := packager.Validate(r.Params, v.request); err != nil {
+		return yar.NewError(yar.ErrorPackager, "request "+r.Method+" "+err.Error())
 	}
 
 	return nil
 }
 
-func (client *Client) httpHandler(method string, ret interface{}, params ...interface{}) *yar.Error {
+func (client *Client) validateResponse(method string, ret interface{}) *yar.Error {
 
-	r, err := client.initRequest(method, params...)
+	v, ok := client.validators[strings.ToLower(method)]
 
-	if err != nil {
-		return err
+	if !ok {
+		return nil
 	}
 
-	packBody, err := client.packRequest(r)
+	if err := packager.Validate(ret, v.response); err != nil {
+		return yar.NewError(yar.ErrorPackager, "response "+method+" "+err.Error())
+	}
 
-	if err != nil {
-		return err
+	return nil
+}
+
+// 解析返回，frame 为传输层读取的第一帧，续帧模式下后续帧从 rest 中读取
+func (client *Client) readResponse(frame *yar.Response, rest io.Reader, r *yar.Request, ret interface{}) (*yar.Response, *yar.Error) {
+
+	method := r.Method
+	protocol := frame.Protocol
+	allBody := frame.Body
+
+	if protocol.MagicNumber != client.Opt.MagicNumber {
+		return nil, yar.NewError(yar.ErrorMagicNumber, fmt.Sprintf("response magic number %s mismatch %s", protocol.MagicNumber, client.Opt.MagicNumber))
+	}
+
+	if protocol.Id != r.Id {
+		return nil, yar.NewError(yar.ErrorResponseId, fmt.Sprintf("response id %d mismatch request id %d", protocol.Id, r.Id))
 	}
 
-	r.Protocol.BodyLength = uint32(len(packBody) + yar.PackagerLength)
+	//只记录与请求对应的返回的版本，错乱的帧不影响协商的版本
+	atomic.StoreInt32(&client.peerVersion, int32(protocol.Version))
+
+	response := yar.AcquireResponse()
 
-	postBuffer := bytes.NewBuffer(r.Protocol.Bytes().Bytes())
-	postBuffer.Write(packBody)
+	//有序 map 直接解包，避免经过 map[string]interface{} 中转后丢失键顺序
+	ordered, isOrdered := ret.(*packager.OrderedMap)
 
-	//todo 停止验证HTTPS请求
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	if isOrdered {
+		response.Retval = ordered
 	}
-	tr.DisableKeepAlives = true
-	if client.Opt.DNSCache == true {
-		tr.Dial = func(network string, address string) (net.Conn, error) {
-			separator := strings.LastIndex(address, ":")
-			ips, err := globalResolver.Lookup(address[:separator])
-			if err != nil {
-				return nil, errors.New("Lookup Error:" + err.Error())
-			}
-			if len(ips) < 1 {
-				return nil, errors.New("Lookup Error: No IP Resolver Result Found")
+
+	var err error
+
+	if protocol.HasFlag(yar.FlagChunked) {
+
+		var chunks io.Reader = bytes.NewReader(allBody)
+		if rest != nil {
+			chunks = io.MultiReader(chunks, rest)
+		}
+		cr := packager.NewChunkReader(chunks)
+		err = packager.UnpackFrom([]byte(client.Opt.Packager), cr, &response)
+		//读取到结束的空块，保证连接停留在帧的边界上
+		io.Copy(ioutil.Discard, cr)
+
+	} else if protocol.HasFlag(yar.FlagContinuation) {
+
+		fr := yar.NewFrameReader(io.MultiReader(bytes.NewReader(allBody), rest), protocol)
+		err = packager.UnpackFrom([]byte(client.Opt.Packager), fr, &response)
+		//读取完剩余的数据，保证连接停留在帧的边界上
+		io.Copy(ioutil.Discard, fr)
+
+	} else {
+
+		bodyLength := protocol.BodyLength - yar.PackagerLength
+
+		if uint32(len(allBody)) < bodyLength {
+			return nil, yar.NewError(yar.ErrorBodyLength, "Response Content Error:"+string(allBody))
+		}
+
+		body := allBody[:bodyLength]
+
+		if protocol.HasFlag(yar.FlagTrailer) {
+
+			var trailerErr error
+			body, response.Trailer, trailerErr = yar.SplitTrailer(body)
+
+			if trailerErr != nil {
+				return nil, yar.NewError(yar.ErrorResponse, "Response Trailer Error:"+trailerErr.Error())
 			}
-			return net.Dial("tcp", ips[0].String()+address[separator:])
 		}
+
+		if len(client.Opt.SignSecret) > 0 && !yar.VerifyHeader(protocol, client.Opt.SignSecret, body) {
+			return nil, yar.NewError(yar.ErrorVerify, "response signature mismatch")
+		}
+
+		body, decodeErr := yar.DecodeBody(protocol, client.Opt, body)
+
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+
+		err = packager.Unpack([]byte(client.Opt.Packager), body, &response)
+	}
+
+	if err != nil {
+		return nil, yar.NewError(yar.ErrorPackager, "Unpack Error:"+err.Error())
 	}
 
-	httpClient := &http.Client{
-		Transport: tr,
-		Timeout:   time.Duration(client.Opt.Timeout) * time.Millisecond,
+	if response.Id != 0 && response.Id != r.Id {
+		return nil, yar.NewError(yar.ErrorResponseId, fmt.Sprintf("response body id %d mismatch request id %d", response.Id, r.Id))
 	}
 
-	resp, postErr := httpClient.Post(client.hostname, "application/json", postBuffer)
+	if response.Status != yar.ERR_OKEY {
+		return nil, yar.NewStatusError(response.Status, response.Error)
+	}
 
-	if postErr != nil {
-		return yar.NewError(yar.ErrorNetwork, postErr.Error())
+	if isOrdered {
+		return response, client.validateResponse(method, ordered)
 	}
 
-	responseErr := client.readResponse(resp.Body, ret)
-	return responseErr
-}
+	if ret != nil {
+
+		packData, err := packager.Pack([]byte(client.Opt.Packager), response.Retval)
+
+		if err != nil {
+			return nil, yar.NewError(yar.ErrorPackager, "pack response retval error:"+err.Error()+" "+string(allBody))
+		}
+
+		err = packager.Unpack([]byte(client.Opt.Packager), packData, ret)
+
+		if err != nil {
+			return nil, yar.NewError(yar.ErrorPackager, "pack response retval error:"+err.Error()+" "+string(allBody))
+		}
+
+		return response, client.validateResponse(method, ret)
+	}
 
-func (client *Client) sockHandler(method string, ret interface{}, params ...interface{}) *yar.Error {
-	return yar.NewError(yar.ErrorParam, "unsupported sock request")
+	return response, client.validateResponse(method, response.Retval)
 }
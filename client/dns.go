@@ -1,32 +1,58 @@
 package client
 
 import (
+	"context"
 	"net"
 	"sync"
 	"time"
 )
 
+// Resolver 域名解析，可以通过 Client.SetResolver 替换为自己的实现(如 Consul DNS)
+type Resolver interface {
+	Lookup(ctx context.Context, host string) ([]net.IP, error)
+}
+
+// ResolverFunc 将函数作为 Resolver 使用
+type ResolverFunc func(ctx context.Context, host string) ([]net.IP, error)
+
+func (f ResolverFunc) Lookup(ctx context.Context, host string) ([]net.IP, error) {
+	return f(ctx, host)
+}
+
+// SystemResolver 使用系统的域名解析，不缓存
+var SystemResolver Resolver = ResolverFunc(func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+})
+
 type ResolverResult struct {
 	list    []net.IP
-	Expired int
+	Expired int64
 }
 
-type Resolver struct {
-	cache  map[string]ResolverResult
-	lock   sync.RWMutex
-	Expire time.Duration
-	Max    int
+// CacheResolver 在内存中缓存解析结果
+type CacheResolver struct {
+	upstream Resolver
+	cache    map[string]ResolverResult
+	lock     sync.RWMutex
+	expire   time.Duration
+	max      int
 }
 
-func NewResolver(max int, expires time.Duration) *Resolver {
-	r := new(Resolver)
-	r.Max = max
-	r.Expire = expires
+// NewCacheResolver 缓存 upstream 的解析结果 ttl 时间，最多缓存 max 个域名
+// upstream 为空时使用系统的域名解析，ttl 为 0 表示不过期，max 为 0 表示不限制
+func NewCacheResolver(upstream Resolver, max int, ttl time.Duration) *CacheResolver {
+	if upstream == nil {
+		upstream = SystemResolver
+	}
+	r := new(CacheResolver)
+	r.upstream = upstream
+	r.max = max
+	r.expire = ttl
 	r.cache = make(map[string]ResolverResult)
 	return r
 }
 
-func (r *Resolver) Lookup(domain string) ([]net.IP, error) {
+func (r *CacheResolver) Lookup(ctx context.Context, domain string) ([]net.IP, error) {
 	now := time.Now().Unix()
 	r.lock.RLock()
 	ret, ok := r.cache[domain]
@@ -35,40 +61,88 @@ func (r *Resolver) Lookup(domain string) ([]net.IP, error) {
 		if ret.Expired == 0 {
 			return ret.list, nil
 		}
-		if ret.Expired > int(now) {
+		if ret.Expired > now {
 			return ret.list, nil
 		}
 	}
-	ips, err := net.LookupIP(domain)
+	ips, err := r.upstream.Lookup(ctx, domain)
 	if err != nil {
 		return nil, err
 	}
 	ret = ResolverResult{
 		list: ips,
 	}
-	if r.Expire > 0 {
-		ret.Expired = int(now) + int(r.Expire.Seconds())
-	}
 
 	r.lock.Lock()
+	if r.expire > 0 {
+		ret.Expired = now + int64(r.expire.Seconds())
+	}
 	r.cache[domain] = ret
-	if r.Max > 0 && r.Max < len(r.cache) {
-		i := 0
-		newCache := make(map[string]ResolverResult)
-		for k, item := range r.cache {
-			newCache[k] = item
-			if i > r.Max {
-				break
-			}
+	//超出数量时随机淘汰
+	for k := range r.cache {
+		if r.max <= 0 || len(r.cache) <= r.max {
+			break
+		}
+		if k != domain {
+			delete(r.cache, k)
 		}
-		r.cache = newCache
 	}
 	r.lock.Unlock()
 	return ips, err
 }
 
-var globalResolver *Resolver = nil
+// SetTTL 修改之后缓存的结果的过期时间，已缓存的结果不受影响
+func (r *CacheResolver) SetTTL(ttl time.Duration) {
+	r.lock.Lock()
+	r.expire = ttl
+	r.lock.Unlock()
+}
 
-func init() {
-	globalResolver = NewResolver(1000, 60*time.Second)
+// Invalidate 删除域名的缓存，下次解析时重新查询
+func (r *CacheResolver) Invalidate(domain string) {
+	r.lock.Lock()
+	delete(r.cache, domain)
+	r.lock.Unlock()
 }
+
+// Flush 清空所有缓存
+func (r *CacheResolver) Flush() {
+	r.lock.Lock()
+	r.cache = make(map[string]ResolverResult)
+	r.lock.Unlock()
+}
+
+// interleaveIPs 按第一个地址的协议族开始，交替排列 IPv6 与 IPv4 地址(RFC 8305)
+func interleaveIPs(ips []net.IP) []net.IP {
+
+	if len(ips) < 2 {
+		return ips
+	}
+
+	var first, second []net.IP
+	firstV4 := ips[0].To4() != nil
+
+	for _, ip := range ips {
+		if (ip.To4() != nil) == firstV4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+
+	ordered := make([]net.IP, 0, len(ips))
+
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			ordered = append(ordered, first[i])
+		}
+		if i < len(second) {
+			ordered = append(ordered, second[i])
+		}
+	}
+
+	return ordered
+}
+
+// DefaultResolver 开启 Opt.DNSCache 且未设置 SetResolver 时使用，缓存 60 秒
+var DefaultResolver Resolver = NewCacheResolver(nil, 1000, 60*time.Second)
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+func TestFramedRoundTrip(t *testing.T) {
+
+	//服务端 MaxFrameSize 为 0 时按单帧返回，为 16 时同样以续帧返回
+	for i, frameSize := range []int{0, 16} {
+
+		var conns int32
+		addr := []string{"127.0.0.1:15630", "127.0.0.1:15631"}[i]
+
+		sock, _ := transports.NewSock("tcp", addr)
+		sock.OnConnection(func(conn transports.TransportConnection) {
+			atomic.AddInt32(&conns, 1)
+			s := server.NewServer(&loopbackService{})
+			s.Opt.LogLevel = 0
+			s.Opt.Persistent = true
+			s.Opt.MaxFrameSize = frameSize
+			s.ServeConn(conn)
+		})
+
+		go sock.Serve()
+		time.Sleep(50 * time.Millisecond)
+
+		c, _ := NewClient("tcp://" + addr)
+		c.Opt.Persistent = true
+		c.Opt.MaxFrameSize = 16
+		c.Opt.Timeout = 2000
+
+		payload := strings.Repeat("x", 100)
+		var first int32
+
+		//第一次调用得知对端版本，之后的请求以续帧发送
+		for n := 0; n < 4; n++ {
+			var ret string
+			start := time.Now()
+			if err := c.Call("Echo", &ret, payload); err != nil || ret != payload {
+				t.Fatal(frameSize, ret, err)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatal("framed call stalled", frameSize, elapsed)
+			}
+			if n == 0 {
+				first = atomic.LoadInt32(&conns)
+			}
+		}
+
+		//得知对端版本后的调用共用一条连接
+		if n := atomic.LoadInt32(&conns) - first; n != 1 {
+			t.Fatal("persistent connection not reused", frameSize, n)
+		}
+
+		sock.Close()
+	}
+}
+
+func TestChunkedPersistent(t *testing.T) {
+
+	var conns int32
+	addr := "127.0.0.1:15658"
+
+	sock, _ := transports.NewSock("tcp", addr)
+	sock.OnConnection(func(conn transports.TransportConnection) {
+		atomic.AddInt32(&conns, 1)
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.Opt.Persistent = true
+		s.Opt.ChunkSize = 64
+		s.ServeConn(conn)
+	})
+
+	go sock.Serve()
+	defer sock.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	c, _ := NewClient("tcp://" + addr)
+	c.Opt.Persistent = true
+	c.Opt.ChunkSize = 64
+	c.Opt.Timeout = 2000
+
+	var first int32
+
+	//分块的返回边读取边解包，读完结束块后连接可以继续发送下一个请求
+	for n := 0; n < 4; n++ {
+		payload := strings.Repeat(string(rune('a'+n)), 500+n*100)
+		var ret string
+		if err := c.Call("Echo", &ret, payload); err != nil || ret != payload {
+			t.Fatal(n, len(ret), err)
+		}
+		if n == 0 {
+			first = atomic.LoadInt32(&conns)
+		}
+	}
+
+	if n := atomic.LoadInt32(&conns) - first; n != 1 {
+		t.Fatal("persistent connection not reused after chunked responses", n)
+	}
+}
+
+func TestFramedSharedServer(t *testing.T) {
+
+	//多个连接共用同一个 Server，续帧的后续数据必须从各自的连接中读取
+	s := server.NewServer(&loopbackService{})
+	s.Opt.LogLevel = 0
+
+	loopback := transports.NewLoopback("client-framed-shared")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s.ServeConn(conn)
+	})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, _ := NewClient("loopback://client-framed-shared")
+			c.Opt.MaxFrameSize = 16
+			payload := strings.Repeat(string(rune('a'+i)), 200)
+			for n := 0; n < 10; n++ {
+				var ret string
+				if err := c.Call("Echo", &ret, payload); err != nil || ret != payload {
+					t.Error(i, ret, err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/weixinhost/yar.go/transports"
+)
+
+// HostProbe 检查一个地址(host:port)是否可用，返回 nil 表示可用
+type HostProbe func(ctx context.Context, address string) error
+
+// TCPProbe 能建立 tcp 连接即认为可用
+func TCPProbe(ctx context.Context, address string) error {
+
+	conn, err := transports.DefaultDialContext(ctx, "tcp", address)
+
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// PingProbe 通过 tcp 连接发送 yar 探测帧，服务端回复后认为可用
+func PingProbe(ctx context.Context, address string) error {
+
+	c, err := NewClient("tcp://" + address)
+
+	if err != nil {
+		return errors.New(err.String())
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline) / time.Millisecond
+		if timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+		c.Opt.ConnectTimeout = uint32(timeout)
+		c.Opt.Timeout = uint32(timeout)
+	}
+
+	defer c.transport.Close()
+
+	if err = c.Ping(); err != nil {
+		return errors.New(err.String())
+	}
+
+	return nil
+}
+
+// FilterHealthy 最多同时探测 concurrency 个地址，返回可用的地址，保持原来的顺序
+// concurrency 为 0 表示同时探测所有的地址，probe 为空时使用 TCPProbe
+func FilterHealthy(ctx context.Context, addresses []string, concurrency int, probe HostProbe) []string {
+
+	if probe == nil {
+		probe = TCPProbe
+	}
+
+	if concurrency <= 0 || concurrency > len(addresses) {
+		concurrency = len(addresses)
+	}
+
+	healthy := make([]bool, len(addresses))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, address := range addresses {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, address string) {
+			defer wg.Done()
+			healthy[i] = probe(ctx, address) == nil
+			<-slots
+		}(i, address)
+	}
+
+	wg.Wait()
+
+	var ret []string
+
+	for i, address := range addresses {
+		if healthy[i] {
+			ret = append(ret, address)
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// HostFile 从 JSON 文件加载各 pool/service 的地址列表，适合小规模部署及本地开发
+// 文件格式为 {"pool": {"service": ["host:port", ...]}}
+// 为了不引入第三方依赖，有意不支持 YAML，也不使用 fsnotify，Watch 通过定期检查修改时间与大小发现变化
+type HostFile struct {
+	path     string
+	lock     sync.RWMutex
+	hosts    map[string]map[string][]string
+	modified time.Time
+	size     int64
+	done     chan struct{}
+}
+
+func LoadHostFile(path string) (*HostFile, error) {
+
+	file := new(HostFile)
+	file.path = path
+
+	if _, err := file.Reload(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Hosts 返回 pool/service 的地址列表，不存在时返回空
+func (file *HostFile) Hosts(pool string, service string) []string {
+	file.lock.RLock()
+	defer file.lock.RUnlock()
+	return file.hosts[pool][service]
+}
+
+// Reload 文件的修改时间或大小变化时重新加载，解析失败时保留原来的列表
+func (file *HostFile) Reload() (bool, error) {
+
+	info, err := os.Stat(file.path)
+
+	if err != nil {
+		return false, err
+	}
+
+	file.lock.RLock()
+	unchanged := file.hosts != nil && info.ModTime().Equal(file.modified) && info.Size() == file.size
+	file.lock.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	start := time.Now()
+	data, err := ioutil.ReadFile(file.path)
+	hosts := make(map[string]map[string][]string)
+
+	if err == nil {
+		err = json.Unmarshal(data, &hosts)
+	}
+
+	observeDiscovery(discoveryLabels("file", file.path), start, err)
+
+	if err != nil {
+		return false, err
+	}
+
+	file.lock.Lock()
+	previous := file.hosts
+	file.hosts = hosts
+	file.modified = info.ModTime()
+	file.size = info.Size()
+	file.lock.Unlock()
+
+	for pool, services := range hosts {
+		for service, list := range services {
+			observeHosts(discoveryLabels("file", pool+"/"+service), len(list), !sameHosts(previous[pool][service], list))
+		}
+	}
+
+	//文件中删除的服务上报为 0
+	for pool, services := range previous {
+		for service := range services {
+			if _, ok := hosts[pool][service]; !ok {
+				observeHosts(discoveryLabels("file", pool+"/"+service), 0, true)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Watch 每隔 interval 检查文件，重新加载后或加载失败时调用 onChange(可以为空)
+// 没有依赖 fsnotify，通过修改时间与大小判断文件是否变化
+func (file *HostFile) Watch(interval time.Duration, onChange func(err error)) {
+
+	file.lock.Lock()
+
+	if file.done != nil {
+		file.lock.Unlock()
+		return
+	}
+
+	done := make(chan struct{})
+	file.done = done
+	file.lock.Unlock()
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				changed, err := file.Reload()
+				if (changed || err != nil) && onChange != nil {
+					onChange(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close 停止 Watch
+func (file *HostFile) Close() error {
+
+	file.lock.Lock()
+
+	if file.done != nil {
+		close(file.done)
+		file.done = nil
+	}
+
+	file.lock.Unlock()
+	return nil
+}
+
+func sameHosts(a []string, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+// SetKeepAlive 每隔 interval 在连接池的空闲连接上发送探测帧，对端已失效的连接在被取出前关闭
+// 服务端回复探测帧时刷新连接的超时时间，interval 小于连接池 IdleTimeout 的一半时空闲连接可以一直保持，传入 0 关闭探测
+func (client *Client) SetKeepAlive(interval time.Duration) *yar.Error {
+
+	sock, ok := client.transport.(*transports.Sock)
+
+	if !ok || sock.Pool() == nil {
+		return yar.NewError(yar.ErrorConfig, "keepalive is only supported on pooled sock transports")
+	}
+
+	config := *sock.Pool().Config()
+	config.KeepAlive = interval
+	config.Ping = client.ping
+
+	sock.SetPool(&config)
+	return nil
+}
+
+// 发送探测帧并等待 pong，pong 不带 FlagPersistent 时连接不能继续使用
+func (client *Client) ping(conn transports.TransportConnection) error {
+
+	persistent, err := client.sendPing(conn)
+
+	if err != nil {
+		return err
+	}
+
+	//不认识探测帧的服务端返回错误，只要同意保持连接同样说明连接可用
+	if !persistent {
+		return errors.New("peer closed the connection after ping")
+	}
+
+	return nil
+}
+
+// Ping 在 tcp/unix/tls 连接上发送探测帧确认服务端可用，不调用任何方法
+func (client *Client) Ping() *yar.Error {
+
+	switch client.net {
+	case "tcp", "unix", "tls":
+	default:
+		return yar.NewError(yar.ErrorConfig, "ping is only supported on tcp/unix/tls connections")
+	}
+
+	conn, err := client.acquireConn()
+
+	if err != nil {
+		return err
+	}
+
+	persistent, pingErr := client.sendPing(conn)
+
+	if pingErr != nil {
+		conn.Close()
+		return yar.NewError(yar.ErrorNetwork, "ping error:"+pingErr.Error())
+	}
+
+	if persistent {
+		client.releaseConn(conn)
+	} else {
+		conn.Close()
+	}
+
+	return nil
+}
+
+func (client *Client) sendPing(conn transports.TransportConnection) (bool, error) {
+
+	r := yar.NewRequest()
+	r.Protocol.MagicNumber = client.Opt.MagicNumber
+	r.Protocol.Version = client.version()
+	r.Protocol.Id = r.Id
+	r.Protocol.SetFlag(yar.FlagPing | yar.FlagPersistent)
+
+	layout, layoutErr := client.layout()
+
+	if layoutErr != nil {
+		return false, errors.New(layoutErr.String())
+	}
+
+	r.Protocol.Layout = layout
+
+	timeout := time.Duration(client.Opt.Timeout) * time.Millisecond
+	conn.SetWriteTimeout(timeout)
+	conn.SetReadTimeout(timeout)
+
+	if err := conn.Send(r); err != nil {
+		return false, err
+	}
+
+	frame := yar.NewResponse()
+
+	if err := conn.Recv(frame); err != nil {
+		return false, err
+	}
+
+	defer yar.ReleaseHeader(frame.Protocol)
+
+	if frame.Protocol.Id != r.Id {
+		return false, fmt.Errorf("pong id %d mismatch ping id %d", frame.Protocol.Id, r.Id)
+	}
+
+	return frame.Protocol.HasFlag(yar.FlagPersistent), nil
+}
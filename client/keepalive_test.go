@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+func TestKeepAlive(t *testing.T) {
+
+	var lock sync.Mutex
+	var conns []transports.TransportConnection
+
+	sock, _ := transports.NewSock("tcp", "127.0.0.1:15659")
+	sock.OnConnection(func(conn transports.TransportConnection) {
+		lock.Lock()
+		conns = append(conns, conn)
+		lock.Unlock()
+		s := server.NewServer(&muxService{})
+		s.Opt.LogLevel = 0
+		s.Opt.Persistent = true
+		s.ServeConn(conn)
+	})
+
+	go sock.Serve()
+	defer sock.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	c, _ := NewClient("tcp://127.0.0.1:15659")
+	c.Opt.Persistent = true
+
+	if err := c.SetKeepAlive(20 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	var ret string
+
+	for i := 0; i < 2; i++ {
+		if err := c.Call("Sleep", &ret, 0, "warm"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := c.transport.(*transports.Sock).Pool()
+
+	//探测成功的连接继续留在池中并被复用
+	time.Sleep(100 * time.Millisecond)
+
+	if pool.Idle() != 1 {
+		t.Fatal("healthy connection evicted", pool.Idle())
+	}
+
+	if err := c.Call("Sleep", &ret, 0, "again"); err != nil {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	opened := len(conns)
+	for _, conn := range conns {
+		conn.Close()
+	}
+	lock.Unlock()
+
+	if opened != 2 {
+		t.Fatal("unexpected connections", opened)
+	}
+
+	//对端断开的连接在探测后被关闭
+	time.Sleep(100 * time.Millisecond)
+
+	if pool.Idle() != 0 {
+		t.Fatal("dead connection kept in pool")
+	}
+}
+
+func TestFilterHealthy(t *testing.T) {
+
+	sock, _ := transports.NewSock("tcp", "127.0.0.1:15670")
+	sock.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&muxService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	go sock.Serve()
+	defer sock.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	addresses := []string{"127.0.0.1:15670", "127.0.0.1:1", "127.0.0.1:15670"}
+
+	for _, probe := range []HostProbe{TCPProbe, PingProbe} {
+		if healthy := FilterHealthy(ctx, addresses, 2, probe); len(healthy) != 2 || healthy[0] != addresses[0] {
+			t.Fatal(healthy)
+		}
+	}
+}
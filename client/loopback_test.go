@@ -0,0 +1,296 @@
+package client
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/packager"
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+type loopbackService struct{}
+
+func (s *loopbackService) Echo(v string) string {
+	return v
+}
+
+func (s *loopbackService) Tag(md yar.Metadata, v string) string {
+	md["tag"] = v
+	return md.Get("trace")
+}
+
+func TestLoopback(t *testing.T) {
+
+	loopback := transports.NewLoopback("client-test")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	c, err := NewClient("loopback://client-test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ret string
+
+	if err := c.Call("Echo", &ret, "hello"); err != nil || ret != "hello" {
+		t.Fatal(ret, err)
+	}
+
+	if _, err := NewClient("loopback://missing"); err == nil {
+		t.Fatal("missing loopback accepted")
+	}
+}
+
+func TestLoopbackStatus(t *testing.T) {
+
+	loopback := transports.NewLoopback("client-status")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	c, _ := NewClient("loopback://client-status")
+	var ret string
+
+	err := c.Call("Missing", &ret)
+
+	if err == nil || err.Status() != yar.ERR_NOT_FOUND || err.Retriable() {
+		t.Fatal(err)
+	}
+
+	//原始协议的对端收到 ERR_EMPTY_RESPONSE
+	c.Opt.Version = yar.ProtocolVersionLegacy
+	err = c.Call("Missing", &ret)
+
+	if err == nil || err.Status() != yar.ERR_EMPTY_RESPONSE {
+		t.Fatal(err)
+	}
+}
+
+func TestLoopbackMetadata(t *testing.T) {
+
+	loopback := transports.NewLoopback("client-metadata")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	c, _ := NewClient("loopback://client-metadata")
+	c.Metadata = yar.Metadata{"trace": "t1"}
+	var ret string
+
+	r, err := c.NewRequest("Tag", "a")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := c.Do(r, &ret)
+
+	if err != nil || ret != "t1" || response.GetMeta("trace") != "t1" || response.GetMeta("tag") != "a" {
+		t.Fatal(ret, response, err)
+	}
+
+	//请求没有带 Metadata 时处理方法写入的值同样返回
+	c.Metadata = nil
+	r, _ = c.NewRequest("Tag", "b")
+	response, err = c.Do(r, &ret)
+
+	if err != nil || ret != "" || response.GetMeta("tag") != "b" {
+		t.Fatal(ret, response, err)
+	}
+}
+
+func TestRequestIdentity(t *testing.T) {
+
+	headers := make(chan *yar.Header, 2)
+	loopback := transports.NewLoopback("client-identity")
+	defer loopback.Close()
+
+	//记录请求的头部后交给服务端处理
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		defer conn.Close()
+		frame, header, err := yar.ReadFrame(conn)
+		if err != nil {
+			return
+		}
+		headers <- header
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.Handle(frame, conn)
+	})
+
+	c, _ := NewClient("loopback://client-identity")
+	c.Opt.Provider = "gateway"
+	c.Opt.Token = "gateway-token"
+	var ret string
+
+	if err := c.Call("Echo", &ret, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if header := <-headers; header.ProviderString() != "gateway" || header.TokenString() != "gateway-token" {
+		t.Fatal(header.ProviderString(), header.TokenString())
+	}
+
+	//单个请求上覆盖 Provider，Token 仍使用 Opt 中的设置
+	r, _ := c.NewRequest("Echo", "b")
+	r.Protocol.SetProvider("tenant")
+
+	if _, err := c.Do(r, &ret); err != nil || ret != "b" {
+		t.Fatal(ret, err)
+	}
+
+	if header := <-headers; header.ProviderString() != "tenant" || header.TokenString() != "gateway-token" {
+		t.Fatal(header.ProviderString(), header.TokenString())
+	}
+}
+
+func TestIdGenerator(t *testing.T) {
+
+	loopback := transports.NewLoopback("client-id")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	yar.SetIdGenerator(yar.IdGeneratorFunc(func() uint32 {
+		return 0x5eed
+	}))
+	defer yar.SetIdGenerator(nil)
+
+	c, _ := NewClient("loopback://client-id")
+	r, err := c.NewRequest("Echo", "id")
+
+	if err != nil || r.Id != 0x5eed || r.Protocol.Id != 0x5eed {
+		t.Fatal(r, err)
+	}
+
+	//服务端按请求头部的 Id 返回
+	var ret string
+	response, err := c.Do(r, &ret)
+
+	if err != nil || response.Id != 0x5eed {
+		t.Fatal(response, err)
+	}
+}
+
+func TestValidator(t *testing.T) {
+
+	var calls int32
+	loopback := transports.NewLoopback("client-validator")
+	defer loopback.Close()
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		atomic.AddInt32(&calls, 1)
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	c, _ := NewClient("loopback://client-validator")
+
+	//参数为空字符串时不发出请求
+	c.SetValidator("Echo", func(v interface{}) []string {
+		if params, _ := v.([]interface{}); len(params) != 1 || params[0] == "" {
+			return []string{"v is required"}
+		}
+		return nil
+	}, func(v interface{}) []string {
+		if ret, _ := v.(*string); ret == nil || *ret == "reject" {
+			return []string{"rejected response"}
+		}
+		return nil
+	})
+
+	var ret string
+
+	if err := c.Call("echo", &ret, ""); err == nil || !err.Assert(yar.ErrorPackager) || !strings.Contains(err.String(), "v is required") {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatal("rejected request was sent", n)
+	}
+
+	if err := c.Call("Echo", &ret, "ok"); err != nil || ret != "ok" {
+		t.Fatal(ret, err)
+	}
+
+	//返回校验失败时错误交给调用方
+	if err := c.Call("Echo", &ret, "reject"); err == nil || !err.Assert(yar.ErrorPackager) || !strings.Contains(err.String(), "rejected response") {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatal("unexpected calls", n)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		corrupt  func(h *yar.Header)
+		expected yar.ErrorEnum
+	}{
+		{"client-bad-magic", func(h *yar.Header) { h.MagicNumber = 0x12345678 }, yar.ErrorMagicNumber},
+		{"client-bad-id", func(h *yar.Header) { h.Id++ }, yar.ErrorResponseId},
+		{"client-bad-length", func(h *yar.Header) { h.BodyLength = yar.MaxBodyLength + 1 }, yar.ErrorBodyLength},
+	}
+
+	for _, test := range tests {
+
+		corrupt := test.corrupt
+		loopback := transports.NewLoopback(test.name)
+
+		//读取请求后写回头部被篡改的返回
+		loopback.OnConnection(func(conn transports.TransportConnection) {
+			defer conn.Close()
+			_, header, err := yar.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			body, _ := packager.Pack([]byte("json"), &yar.Response{Id: header.Id, Retval: "ok"})
+			h := yar.NewHeader()
+			h.Id = header.Id
+			h.Version = header.Version
+			h.SetPackager("json")
+			h.BodyLength = uint32(len(body) + yar.PackagerLength)
+			corrupt(h)
+			conn.Write(append(h.Bytes().Bytes(), body...))
+		})
+
+		c, _ := NewClient("loopback://" + test.name)
+		var ret string
+		err := c.Call("Echo", &ret, "hello")
+		loopback.Close()
+
+		if err == nil || !err.Assert(test.expected) {
+			t.Fatal(test.name, err)
+		}
+
+		//被拒绝的返回不能设置协商的版本
+		if v := atomic.LoadInt32(&c.peerVersion); v != -1 {
+			t.Fatal(test.name, "peer version recorded from a rejected response", v)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+// 服务发现上报的指标：
+// yar.discovery.hosts     各服务发现的地址数
+// yar.discovery.duration  查询 SRV 记录、加载文件的耗时
+// yar.discovery.errors    查询或加载失败次数
+// yar.discovery.changes   地址列表变化次数
+// 标签 source 为 srv 或 file，service 为 SRV 名称或 pool/service
+func discoveryLabels(source string, service string) map[string]string {
+	return map[string]string{"source": source, "service": service}
+}
+
+func observeDiscovery(labels map[string]string, start time.Time, err error) {
+
+	if err != nil {
+		metrics.Counter("yar.discovery.errors", 1, labels)
+		return
+	}
+
+	metrics.Since("yar.discovery.duration", start, labels)
+}
+
+func observeHosts(labels map[string]string, hosts int, changed bool) {
+
+	metrics.Gauge("yar.discovery.hosts", float64(hosts), labels)
+
+	if changed {
+		metrics.Counter("yar.discovery.changes", 1, labels)
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics/metricstest"
+)
+
+func TestSRVMetrics(t *testing.T) {
+
+	recorder, restore := metricstest.Install()
+	defer restore()
+
+	calls := 0
+	source := NewSRVSource("yar", "tcp", "example.internal", 0)
+	source.SetLookup(func(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error) {
+		calls++
+		if calls > 2 {
+			return "", nil, errors.New("unreachable")
+		}
+		return "_yar._tcp.example.internal.", []*net.SRV{
+			{Target: "a.example.internal.", Port: 5600, Priority: 10, Weight: 1},
+			{Target: "b.example.internal.", Port: 5600, Priority: 10, Weight: 1},
+		}, nil
+	})
+
+	labels := map[string]string{"source": "srv", "service": "_yar._tcp.example.internal"}
+
+	for i := 0; i < 3; i++ {
+		source.Targets(context.Background())
+	}
+
+	hosts := recorder.Records("yar.discovery.hosts", labels)
+
+	if len(hosts) != 2 || hosts[0].Kind != "gauge" || hosts[0].Value != 2 {
+		t.Fatal(hosts)
+	}
+
+	//第二次查询的结果没有变化
+	if recorder.Sum("yar.discovery.changes", labels) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.changes", nil))
+	}
+
+	if len(recorder.Records("yar.discovery.duration", labels)) != 2 || recorder.Sum("yar.discovery.errors", labels) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.duration", nil), recorder.Records("yar.discovery.errors", nil))
+	}
+}
+
+func TestHostFileMetrics(t *testing.T) {
+
+	recorder, restore := metricstest.Install()
+	defer restore()
+
+	path := filepath.Join(t.TempDir(), "hosts.json")
+
+	if err := ioutil.WriteFile(path, []byte(`{"web":{"user":["10.0.0.1:5600"],"order":["10.0.0.2:5600"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := LoadHostFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := map[string]string{"source": "file", "service": "web/user"}
+	order := map[string]string{"source": "file", "service": "web/order"}
+
+	if recorder.Sum("yar.discovery.hosts", user) != 1 || recorder.Sum("yar.discovery.changes", user) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.hosts", nil))
+	}
+
+	if len(recorder.Records("yar.discovery.duration", map[string]string{"source": "file", "service": path})) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.duration", nil))
+	}
+
+	//删除的服务上报为 0
+	recorder.Reset()
+	rewrite(t, path, `{"web":{"user":["10.0.0.1:5600"]}}`)
+
+	if _, err = file.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if records := recorder.Records("yar.discovery.hosts", order); len(records) != 1 || records[0].Value != 0 {
+		t.Fatal(records)
+	}
+
+	if recorder.Sum("yar.discovery.changes", user) != 0 || recorder.Sum("yar.discovery.changes", order) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.changes", nil))
+	}
+
+	rewrite(t, path, `{"web":`)
+
+	if _, err = file.Reload(); err == nil {
+		t.Fatal("invalid host file accepted")
+	}
+
+	if recorder.Sum("yar.discovery.errors", map[string]string{"source": "file", "service": path}) != 1 {
+		t.Fatal(recorder.Records("yar.discovery.errors", nil))
+	}
+}
+
+// 写入新内容并推后修改时间，保证 Reload 能发现变化
+func rewrite(t *testing.T, path string, content string) {
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := time.Now().Add(time.Minute)
+
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
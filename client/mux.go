@@ -0,0 +1,262 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+// 对端是否支持在同一连接上并发请求
+const (
+	muxUnknown int32 = iota
+	//muxProbing 第一个请求正在确认对端是否支持，期间其它请求使用普通的连接
+	muxProbing
+	muxSupported
+	muxUnsupported
+)
+
+// 空闲超过该时间的连接不再使用，服务端默认 5 秒后断开连接
+const muxIdleTimeout = 4 * time.Second
+
+var errMuxClosed = errors.New("multiplexed connection closed")
+
+// muxConn 并发发送请求的连接，读取的返回按头部的 Id 交给等待的请求
+type muxConn struct {
+	conn     transports.TransportConnection
+	lock     sync.Mutex
+	pending  map[uint32]chan *yar.Response
+	lastUsed time.Time
+	err      error
+}
+
+// muxSender 在并发连接上写出一个请求，写出的截止时间为该请求的截止时间，不修改共享连接的写超时
+type muxSender struct {
+	transports.TransportConnection
+	deadline time.Time
+}
+
+func (sender muxSender) Send(r *yar.Request) error {
+	return transports.SendBefore(sender.TransportConnection, r, sender.deadline)
+}
+
+func newMuxConn(conn transports.TransportConnection) *muxConn {
+	mc := new(muxConn)
+	mc.conn = conn
+	mc.pending = make(map[uint32]chan *yar.Response)
+	mc.lastUsed = time.Now()
+	//读取不设置超时，每个请求单独计时
+	conn.SetDeadline(time.Time{})
+	go mc.readLoop()
+	return mc
+}
+
+func (mc *muxConn) readLoop() {
+
+	for {
+
+		frame := yar.NewResponse()
+
+		if err := mc.conn.Recv(frame); err != nil {
+			mc.fail(err)
+			return
+		}
+
+		if frame.Protocol.HasFlag(yar.FlagContinuation) {
+			yar.ReleaseHeader(frame.Protocol)
+			mc.fail(errors.New("continuation frames are not supported on multiplexed connections"))
+			return
+		}
+
+		mc.lock.Lock()
+		ch, ok := mc.pending[frame.Protocol.Id]
+		delete(mc.pending, frame.Protocol.Id)
+		mc.lastUsed = time.Now()
+		mc.lock.Unlock()
+
+		//已超时的请求的返回直接丢弃
+		if !ok {
+			yar.ReleaseHeader(frame.Protocol)
+			continue
+		}
+
+		ch <- frame
+	}
+}
+
+// 等待 Id 对应的返回，连接失效时收到 nil
+func (mc *muxConn) register(id uint32) (chan *yar.Response, error) {
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	if mc.err != nil {
+		return nil, mc.err
+	}
+
+	ch := make(chan *yar.Response, 1)
+	mc.pending[id] = ch
+	mc.lastUsed = time.Now()
+	return ch, nil
+}
+
+func (mc *muxConn) unregister(id uint32) {
+	mc.lock.Lock()
+	delete(mc.pending, id)
+	mc.lock.Unlock()
+}
+
+func (mc *muxConn) usable() bool {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return mc.err == nil && (len(mc.pending) > 0 || time.Since(mc.lastUsed) < muxIdleTimeout)
+}
+
+// 关闭连接，所有等待中的请求失败
+func (mc *muxConn) fail(err error) {
+
+	mc.lock.Lock()
+
+	if mc.err == nil {
+		mc.err = err
+	}
+
+	for id, ch := range mc.pending {
+		ch <- nil
+		delete(mc.pending, id)
+	}
+
+	mc.lock.Unlock()
+	mc.conn.Close()
+}
+
+func (mc *muxConn) error() error {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return mc.err
+}
+
+// Opt.Multiplex 开启时，tcp/unix/tls 连接上的单帧请求可以并发发送
+func (client *Client) multiplexed() bool {
+
+	if !client.Opt.Multiplex || !client.extensions() || client.chunked() || client.framed() {
+		return false
+	}
+
+	switch client.net {
+	case "tcp", "unix", "tls":
+		return atomic.LoadInt32(&client.muxState) != muxUnsupported
+	}
+
+	return false
+}
+
+// 取得可以继续使用的并发连接，没有时建立新连接
+func (client *Client) acquireMux() (*muxConn, *yar.Error) {
+
+	client.muxLock.Lock()
+	defer client.muxLock.Unlock()
+
+	if client.mux != nil && client.mux.usable() {
+		return client.mux, nil
+	}
+
+	if client.mux != nil {
+		client.mux.fail(errMuxClosed)
+		client.mux = nil
+	}
+
+	conn, err := client.acquireConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	client.mux = newMuxConn(conn)
+	return client.mux, nil
+}
+
+func (client *Client) closeMux() {
+
+	client.muxLock.Lock()
+	defer client.muxLock.Unlock()
+
+	if client.mux != nil {
+		client.mux.fail(errMuxClosed)
+		client.mux = nil
+	}
+}
+
+// 在并发连接上发送请求并等待对应的返回
+// probe 为 true 时根据返回确认对端是否支持，不支持时之后的请求使用普通连接
+func (client *Client) muxRoundTrip(r *yar.Request, ret interface{}, probe bool) (*yar.Response, *yar.Error) {
+
+	if probe {
+		//确认失败时恢复为未知，由下一个请求重新确认
+		defer atomic.CompareAndSwapInt32(&client.muxState, muxProbing, muxUnknown)
+	}
+
+	r.Protocol.SetFlag(yar.FlagPersistent | yar.FlagMultiplex)
+
+	deadline, err := client.deadline(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mc, err := client.acquireMux()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ch, registerErr := mc.register(r.Protocol.Id)
+
+	if registerErr != nil {
+		return nil, yar.NewError(yar.ErrorNetwork, "write request error:"+registerErr.Error())
+	}
+
+	//sock 连接上并发写出的请求合并为一次 writev，不会交错，写超时按合并的请求中最晚的截止时间设置
+	err = client.writeRequest(muxSender{mc.conn, deadline}, r)
+
+	if err != nil {
+		if err.Assert(yar.ErrorNetwork) {
+			mc.fail(errors.New(err.String()))
+		} else {
+			mc.unregister(r.Protocol.Id)
+		}
+		return nil, err
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	var frame *yar.Response
+
+	select {
+	case frame = <-ch:
+	case <-timer.C:
+		mc.unregister(r.Protocol.Id)
+		return nil, yar.NewError(yar.ErrorNetwork, "read response error:timeout")
+	}
+
+	if frame == nil {
+		return nil, yar.NewError(yar.ErrorNetwork, "read response error:"+mc.error().Error())
+	}
+
+	defer yar.ReleaseHeader(frame.Protocol)
+
+	if probe {
+		if frame.Protocol.HasFlag(yar.FlagPersistent) && frame.Protocol.HasFlag(yar.FlagMultiplex) {
+			atomic.StoreInt32(&client.muxState, muxSupported)
+		} else {
+			atomic.StoreInt32(&client.muxState, muxUnsupported)
+			defer client.closeMux()
+		}
+	}
+
+	return client.readResponse(frame, nil, r, ret)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+type muxService struct{}
+
+func (s *muxService) Sleep(ms int, tag string) string {
+	time.Sleep(time.Duration(ms) * time.Millisecond)
+	return tag
+}
+
+func TestMultiplex(t *testing.T) {
+
+	var conns int32
+
+	sock, _ := transports.NewSock("tcp", "127.0.0.1:15651")
+	sock.OnConnection(func(conn transports.TransportConnection) {
+		atomic.AddInt32(&conns, 1)
+		s := server.NewServer(&muxService{})
+		s.Opt.LogLevel = 0
+		s.Opt.Persistent = true
+		s.Opt.Multiplex = true
+		s.ServeConn(conn)
+	})
+
+	go sock.Serve()
+	defer sock.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	c, _ := NewClient("tcp://127.0.0.1:15651")
+	c.Opt.Persistent = true
+	c.Opt.Multiplex = true
+
+	var ret string
+
+	//前两次调用分别得知对端版本与确认对端支持并发
+	for i := 0; i < 2; i++ {
+		if err := c.Call("Sleep", &ret, 1, "warm"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var r string
+			tag := fmt.Sprint("t", i)
+			if err := c.Call("Sleep", &r, 50, tag); err != nil || r != tag {
+				t.Error(tag, r, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&conns); n > 2 {
+		t.Fatal("requests not multiplexed, connections:", n)
+	}
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SRVTarget SRV 记录中的一个地址
+type SRVTarget struct {
+	Address  string
+	Priority uint16
+	Weight   uint16
+}
+
+// SRVLookupFunc 查询 SRV 记录，与 net.Resolver.LookupSRV 相同
+type SRVLookupFunc func(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error)
+
+// SRVSource 通过 DNS SRV 记录(_service._proto.name)发现服务的地址，适合只通过 DNS 暴露服务的环境
+// 标准库不返回记录的 TTL，结果按 refresh 缓存，refresh 为 0 表示每次都查询
+type SRVSource struct {
+	service string
+	proto   string
+	name    string
+	refresh time.Duration
+	lookup  SRVLookupFunc
+	lock    sync.Mutex
+	targets []SRVTarget
+	expired time.Time
+}
+
+func NewSRVSource(service string, proto string, name string, refresh time.Duration) *SRVSource {
+	source := new(SRVSource)
+	source.service = service
+	source.proto = proto
+	source.name = name
+	source.refresh = refresh
+	source.lookup = net.DefaultResolver.LookupSRV
+	return source
+}
+
+// SetLookup 替换查询 SRV 记录的函数，如指定 DNS 服务器的 net.Resolver，传入 nil 恢复默认
+func (source *SRVSource) SetLookup(lookup SRVLookupFunc) {
+
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+
+	source.lock.Lock()
+	source.lookup = lookup
+	source.expired = time.Time{}
+	source.lock.Unlock()
+}
+
+// Targets 返回按 Priority 从小到大、同优先级按 Weight 从大到小排列的地址
+// 查询失败时返回上一次成功的结果及错误
+func (source *SRVSource) Targets(ctx context.Context) ([]SRVTarget, error) {
+
+	source.lock.Lock()
+	defer source.lock.Unlock()
+
+	if source.targets != nil && time.Now().Before(source.expired) {
+		return source.targets, nil
+	}
+
+	start := time.Now()
+	labels := discoveryLabels("srv", "_"+source.service+"._"+source.proto+"."+source.name)
+	_, records, err := source.lookup(ctx, source.service, source.proto, source.name)
+	observeDiscovery(labels, start, err)
+
+	if err != nil {
+		return source.targets, err
+	}
+
+	targets := make([]SRVTarget, 0, len(records))
+
+	for _, record := range records {
+		//"." 表示该服务不可用(RFC 2782)
+		host := strings.TrimSuffix(record.Target, ".")
+		if len(host) < 1 {
+			continue
+		}
+		targets = append(targets, SRVTarget{
+			Address:  net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+			Priority: record.Priority,
+			Weight:   record.Weight,
+		})
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].Priority != targets[j].Priority {
+			return targets[i].Priority < targets[j].Priority
+		}
+		return targets[i].Weight > targets[j].Weight
+	})
+
+	observeHosts(labels, len(targets), !sameTargets(source.targets, targets))
+	source.targets = targets
+	source.expired = time.Now().Add(source.refresh)
+	return targets, nil
+}
+
+// Addresses 返回优先级最高的一组地址(host:port)，可以用于 NewClient("tcp://" + address)
+func (source *SRVSource) Addresses(ctx context.Context) ([]string, error) {
+
+	targets, err := source.Targets(ctx)
+
+	var addresses []string
+
+	for _, target := range targets {
+		if target.Priority != targets[0].Priority {
+			break
+		}
+		addresses = append(addresses, target.Address)
+	}
+
+	return addresses, err
+}
+
+func sameTargets(a []SRVTarget, b []SRVTarget) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
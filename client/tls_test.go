@@ -0,0 +1,77 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/server"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+func TestTLSRoundTrip(t *testing.T) {
+
+	//借用 httptest 的证书，对 example.com 及 127.0.0.1 有效
+	issuer := httptest.NewUnstartedServer(nil)
+	issuer.StartTLS()
+	cert := issuer.TLS.Certificates[0]
+	pool := x509.NewCertPool()
+	pool.AddCert(issuer.Certificate())
+	issuer.Close()
+
+	addr := "127.0.0.1:15643"
+	sock, _ := transports.NewSock("tcp", addr)
+	sock.SetTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}})
+	sock.OnConnection(func(conn transports.TransportConnection) {
+		s := server.NewServer(&loopbackService{})
+		s.Opt.LogLevel = 0
+		s.ServeConn(conn)
+	})
+
+	go sock.Serve()
+	defer sock.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	c, err := NewClient("tls://" + addr)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Opt.Timeout = 2000
+
+	if err := c.SetRootCAs(pool); err != nil {
+		t.Fatal(err)
+	}
+
+	var ret string
+
+	if err := c.Call("Echo", &ret, "secure"); err != nil || ret != "secure" {
+		t.Fatal(ret, err)
+	}
+
+	//SNI 与证书不符时握手失败
+	c.SetServerName("example.org")
+
+	if err := c.Call("Echo", &ret, "secure"); err == nil || !strings.Contains(err.String(), "certificate") {
+		t.Fatal("certificate for another host accepted", err)
+	}
+
+	//系统的根证书不包含 httptest 的 CA
+	c, _ = NewClient("tls://" + addr)
+	c.Opt.Timeout = 2000
+
+	if err := c.Call("Echo", &ret, "secure"); err == nil || !strings.Contains(err.String(), "certificate") {
+		t.Fatal("certificate accepted without its root", err)
+	}
+
+	//tcp:// 地址不支持 TLS 设置
+	c, _ = NewClient("tcp://" + addr)
+
+	if err := c.SetRootCAs(pool); err == nil {
+		t.Fatal("root CAs accepted on tcp://")
+	}
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+// 通过传输层发送请求并读取返回
+// tcp/unix 连接下开启 Opt.Persistent 且服务端同意时，连接在返回后保留给下一次调用复用
+func (client *Client) roundTrip(r *yar.Request, ret interface{}) (*yar.Response, *yar.Error) {
+
+	if err := client.validateRequest(r); err != nil {
+		return nil, err
+	}
+
+	if client.multiplexed() {
+		state := atomic.LoadInt32(&client.muxState)
+		if state == muxSupported || atomic.CompareAndSwapInt32(&client.muxState, muxUnknown, muxProbing) {
+			return client.muxRoundTrip(r, ret, state != muxSupported)
+		}
+	}
+
+	if client.Opt.Persistent && client.extensions() && client.net != "http" && client.net != "https" {
+		r.Protocol.SetFlag(yar.FlagPersistent)
+	}
+
+	deadline, err := client.deadline(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.acquireConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(deadline)
+
+	if err = client.writeRequest(conn, r); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	frame := yar.NewResponse()
+	var readErr error
+
+	if r.Protocol.HasFlag(yar.FlagChunked) {
+		//分块模式的返回边读取边解包，不在内存中缓存完整的数据
+		readErr = recvHeader(conn, frame)
+	} else {
+		readErr = conn.Recv(frame)
+	}
+
+	if readErr == yar.ErrBodyLengthUnderflow || readErr == yar.ErrBodyLengthOverflow {
+		conn.Close()
+		return nil, yar.NewError(yar.ErrorBodyLength, "Response Parse Error:"+readErr.Error())
+	}
+
+	if readErr != nil {
+		conn.Close()
+		return nil, yar.NewError(yar.ErrorNetwork, "read response error:"+readErr.Error())
+	}
+
+	//续帧模式下后续帧仍在连接中
+	response, err := client.readResponse(frame, conn, r, ret)
+
+	if r.Protocol.HasFlag(yar.FlagPersistent) && frame.Protocol.HasFlag(yar.FlagPersistent) && (err == nil || err.Assert(yar.ErrorResponse)) {
+		client.releaseConn(conn)
+	} else {
+		conn.Close()
+	}
+
+	yar.ReleaseHeader(frame.Protocol)
+	return response, err
+}
+
+// 只写出请求，写完后关闭连接
+func (client *Client) send(r *yar.Request) *yar.Error {
+
+	if err := client.validateRequest(r); err != nil {
+		return err
+	}
+
+	conn, err := client.acquireConn()
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Duration(client.Opt.Timeout) * time.Millisecond))
+	return client.writeRequest(conn, r)
+}
+
+// 本次调用的截止时间，r.Deadline 早于 Opt.Timeout 时使用 r.Deadline，已经过了截止时间时不再发送
+func (client *Client) deadline(r *yar.Request) (time.Time, *yar.Error) {
+
+	deadline := time.Now().Add(time.Duration(client.Opt.Timeout) * time.Millisecond)
+
+	if r.Deadline.IsZero() || deadline.Before(r.Deadline) {
+		return deadline, nil
+	}
+
+	if !time.Now().Before(r.Deadline) {
+		return r.Deadline, yar.NewError(yar.ErrorNetwork, "write request error:deadline exceeded")
+	}
+
+	return r.Deadline, nil
+}
+
+func (client *Client) writeRequest(conn transports.TransportConnection, r *yar.Request) *yar.Error {
+
+	if client.chunked() {
+
+		if err := client.writeChunkedRequest(conn, r); err != nil {
+			return yar.NewError(yar.ErrorNetwork, "write request error:"+err.Error())
+		}
+
+		return nil
+	}
+
+	if client.framed() {
+
+		if err := client.writeFramedRequest(conn, r); err != nil {
+			return yar.NewError(yar.ErrorNetwork, "write request error:"+err.Error())
+		}
+
+		return nil
+	}
+
+	layout, err := client.layout()
+
+	if err != nil {
+		return err
+	}
+
+	packBody, err := client.packRequest(r)
+
+	if err != nil {
+		return err
+	}
+
+	r.Protocol.Layout = layout
+	r.Body = packBody
+
+	if writeErr := conn.Send(r); writeErr != nil {
+		return yar.NewError(yar.ErrorNetwork, "write request error:"+writeErr.Error())
+	}
+
+	return nil
+}
+
+// 只读取头部及头部声明的数据，分块数据留在连接中
+func recvHeader(conn io.Reader, frame *yar.Response) error {
+
+	_, header, err := yar.ReadHeader(conn)
+
+	if err != nil {
+		return err
+	}
+
+	body := make([]byte, header.BodyLength-yar.PackagerLength)
+
+	if _, err = io.ReadFull(conn, body); err != nil {
+		yar.ReleaseHeader(header)
+		return err
+	}
+
+	frame.Protocol = header
+	frame.Body = body
+	return nil
+}
+
+func (client *Client) acquireConn() (transports.TransportConnection, *yar.Error) {
+
+	conn, err := client.transport.Connection()
+
+	if err != nil {
+		return nil, yar.NewError(yar.ErrorNetwork, "connect error:"+err.Error())
+	}
+
+	return conn, nil
+}
+
+// 连接可以继续使用时归还给传输层的连接池
+func (client *Client) releaseConn(conn transports.TransportConnection) {
+
+	if pooled, ok := client.transport.(transports.PooledTransport); ok {
+		pooled.Release(conn)
+		return
+	}
+
+	conn.Close()
+}
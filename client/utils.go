@@ -3,12 +3,16 @@ package client
 import "errors"
 import "strings"
 
+import "github.com/weixinhost/yar.go/transports"
+
 var supportNets = []string{
 	"http",
 	"https",
 	"tcp",
+	"tls",
 	"udp",
 	"unix",
+	"unixgram",
 }
 
 func parseAddrNetName(addr string) (string, error) {
@@ -27,6 +31,11 @@ func parseAddrNetName(addr string) (string, error) {
 		}
 	}
 
+	//通过 transports.Register 注册的传输
+	if _, ok := transports.Lookup(protocol); ok {
+		return strings.ToLower(protocol), nil
+	}
+
 	return "", errors.New("unsupport net protocol: " + protocol)
 
 }
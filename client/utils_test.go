@@ -1,9 +1,17 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/weixinhost/yar.go/transports"
 )
 
 func TestParseAddrNet(t *testing.T) {
@@ -28,9 +36,39 @@ func TestParseAddrNet(t *testing.T) {
 	}
 }
 
+func TestParseRegisteredNet(t *testing.T) {
+
+	if _, err := parseAddrNetName("nats://subject"); err == nil {
+		t.Fatal("unregistered scheme accepted")
+	}
+
+	transports.Register("nats", func(address string) (transports.Transport, error) {
+		return transports.NewSock("tcp", address)
+	})
+	defer transports.Register("nats", nil)
+
+	n, err := parseAddrNetName("NATS://subject")
+
+	if err != nil || n != "nats" {
+		t.Fatal(n, err)
+	}
+}
+
+func TestUnregisteredKCP(t *testing.T) {
+
+	//未注册 KCP 实现时 kcp:// 地址可以解析，创建客户端时返回未注册的错误
+	if n, err := parseAddrNetName("kcp://127.0.0.1:15662"); err != nil || n != "kcp" {
+		t.Fatal(n, err)
+	}
+
+	if _, err := NewClient("kcp://127.0.0.1:15662"); err == nil || !strings.Contains(err.String(), "kcp is not registered") {
+		t.Fatal(err)
+	}
+}
+
 func TestDNS(t *testing.T) {
 
-	resolver := NewResolver(10, 10*time.Second)
+	resolver := NewCacheResolver(nil, 10, 10*time.Second)
 
 	var list []string = []string{
 		"www.sina.com.cn",
@@ -49,19 +87,87 @@ func TestDNS(t *testing.T) {
 	}
 
 	for _, v := range list {
-		r, err := resolver.Lookup(v)
+		r, err := resolver.Lookup(context.Background(), v)
 		fmt.Println(v, r, err)
 	}
 	fmt.Println("")
 	for _, v := range list {
-		r, err := resolver.Lookup(v)
+		r, err := resolver.Lookup(context.Background(), v)
 		fmt.Println(v, r, err)
 	}
 
 	time.Sleep(10 * time.Second)
 	fmt.Println("")
 	for _, v := range list {
-		r, err := resolver.Lookup(v)
+		r, err := resolver.Lookup(context.Background(), v)
 		fmt.Println(v, r, err)
 	}
 }
+
+func TestSRVSource(t *testing.T) {
+
+	calls := 0
+	source := NewSRVSource("yar", "tcp", "example.internal", time.Minute)
+	source.SetLookup(func(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error) {
+		calls++
+		if calls > 1 {
+			return "", nil, errors.New("unreachable")
+		}
+		return "_yar._tcp.example.internal.", []*net.SRV{
+			{Target: "b.example.internal.", Port: 5600, Priority: 10, Weight: 1},
+			{Target: "c.example.internal.", Port: 5600, Priority: 20, Weight: 1},
+			{Target: "a.example.internal.", Port: 5601, Priority: 10, Weight: 5},
+			{Target: ".", Port: 0, Priority: 0, Weight: 0},
+		}, nil
+	})
+
+	addresses, err := source.Addresses(context.Background())
+
+	if err != nil || fmt.Sprint(addresses) != "[a.example.internal:5601 b.example.internal:5600]" {
+		t.Fatal(addresses, err)
+	}
+
+	//缓存期间不再查询
+	if _, err = source.Targets(context.Background()); err != nil || calls != 1 {
+		t.Fatal("srv records not cached", calls, err)
+	}
+}
+
+func TestHostFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "hosts.json")
+
+	if err := ioutil.WriteFile(path, []byte(`{"web":{"user":["10.0.0.1:5600"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := LoadHostFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan error, 4)
+	file.Watch(10*time.Millisecond, func(err error) {
+		changed <- err
+	})
+	defer file.Close()
+
+	if hosts := file.Hosts("web", "user"); fmt.Sprint(hosts) != "[10.0.0.1:5600]" {
+		t.Fatal(hosts)
+	}
+
+	if err = ioutil.WriteFile(path, []byte(`{"web":{"user":["10.0.0.1:5600","10.0.0.2:5600"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("host file change not detected")
+	}
+
+	if hosts := file.Hosts("web", "user"); err != nil || len(hosts) != 2 {
+		t.Fatal(hosts, err)
+	}
+}
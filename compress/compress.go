@@ -0,0 +1,98 @@
+package compress
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Compressor 请求体压缩算法
+type Compressor interface {
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+}
+
+// LimitedDecompressor 解压后的数据超过 limit 时返回错误，不按数据中声明的长度预先分配
+type LimitedDecompressor interface {
+	DecompressLimit(src []byte, limit int) ([]byte, error)
+}
+
+// 压缩算法编号，保存在协议头部 Reserved 字段中，取值范围 1-15，0 表示不压缩
+const (
+	None   uint8 = 0
+	Snappy uint8 = 1
+	MaxId  uint8 = 15
+)
+
+// MaxDecodedLength 解压后数据的最大长度，防止恶意数据导致过量分配
+var MaxDecodedLength = 256 * 1024 * 1024
+
+type entry struct {
+	name       string
+	compressor Compressor
+}
+
+var (
+	lock     sync.RWMutex
+	registry = map[uint8]entry{}
+)
+
+func init() {
+	Register(Snappy, "snappy", snappyCompressor{})
+}
+
+// Register 注册压缩算法，可用于接入 lz4 等外部实现
+func Register(id uint8, name string, c Compressor) error {
+
+	if id == None || id > MaxId {
+		return errors.New("compressor id out of range")
+	}
+
+	lock.Lock()
+	registry[id] = entry{name: strings.ToLower(name), compressor: c}
+	lock.Unlock()
+	return nil
+}
+
+// Get 根据编号获取压缩算法
+func Get(id uint8) (Compressor, bool) {
+	lock.RLock()
+	e, ok := registry[id]
+	lock.RUnlock()
+	return e.compressor, ok
+}
+
+// Lookup 根据名称获取压缩算法编号
+func Lookup(name string) (uint8, bool) {
+
+	name = strings.ToLower(name)
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for id, e := range registry {
+		if e.name == name {
+			return id, true
+		}
+	}
+
+	return None, false
+}
+
+// Name 返回编号对应的压缩算法名称
+func Name(id uint8) string {
+
+	if id == None {
+		return "none"
+	}
+
+	lock.RLock()
+	e, ok := registry[id]
+	lock.RUnlock()
+
+	if !ok {
+		return "unknown"
+	}
+
+	return e.name
+}
@@ -0,0 +1,225 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// snappy block 格式的实现，参考 https://github.com/google/snappy/blob/master/format_description.txt
+// 只输出 literal 与 2 字节/3 字节的 copy，解码支持全部 tag
+
+const (
+	snappyBlockSize = 65536
+	snappyTableBits = 14
+	//解码后与编码后长度的最大比例
+	snappyMaxExpansion = 22
+)
+
+var errCorrupt = errors.New("snappy: corrupt input")
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Compress(src []byte) ([]byte, error) {
+
+	dst := make([]byte, 0, binary.MaxVarintLen64+len(src)+len(src)/6+32)
+
+	var lengthBuffer [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lengthBuffer[:], uint64(len(src)))
+	dst = append(dst, lengthBuffer[:n]...)
+
+	for len(src) > 0 {
+
+		block := src
+
+		if len(block) > snappyBlockSize {
+			block = block[:snappyBlockSize]
+		}
+
+		dst = snappyEncodeBlock(dst, block)
+		src = src[len(block):]
+	}
+
+	return dst, nil
+}
+
+func (c snappyCompressor) Decompress(src []byte) ([]byte, error) {
+	return c.DecompressLimit(src, MaxDecodedLength)
+}
+
+// DecompressLimit 头部的长度来自不可信的数据，超过 limit 时直接拒绝，缓冲区按实际解码的数据增长
+func (snappyCompressor) DecompressLimit(src []byte, limit int) ([]byte, error) {
+
+	decodedLength, n := binary.Uvarint(src)
+
+	if n <= 0 || decodedLength > uint64(limit) || decodedLength > uint64(MaxDecodedLength) {
+		return nil, errCorrupt
+	}
+
+	src = src[n:]
+
+	//3 字节的 copy 最多展开为 64 字节，超过该比例的长度不可能是合法数据
+	if decodedLength > uint64(len(src))*snappyMaxExpansion {
+		return nil, errCorrupt
+	}
+
+	size := int(decodedLength)
+
+	if size > 4*len(src) {
+		size = 4 * len(src)
+	}
+
+	dst := make([]byte, 0, size)
+
+	for len(src) > 0 {
+
+		var offset, length int
+		tag := src[0]
+
+		switch tag & 0x03 {
+
+		case 0x00:
+			length = int(tag >> 2)
+			src = src[1:]
+
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, errCorrupt
+				}
+				length = 0
+				for i := 0; i < extra; i++ {
+					length |= int(src[i]) << (8 * uint(i))
+				}
+				src = src[extra:]
+			}
+
+			length++
+
+			if length <= 0 || length > len(src) || len(dst)+length > int(decodedLength) {
+				return nil, errCorrupt
+			}
+
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+
+		case 0x01:
+			if len(src) < 2 {
+				return nil, errCorrupt
+			}
+			length = 4 + int(tag>>2&0x07)
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+
+		case 0x02:
+			if len(src) < 3 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(src[1]) | int(src[2])<<8
+			src = src[3:]
+
+		case 0x03:
+			if len(src) < 5 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:5]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || len(dst)+length > int(decodedLength) {
+			return nil, errCorrupt
+		}
+
+		//允许重叠复制，需逐字节进行
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+
+	if len(dst) != int(decodedLength) {
+		return nil, errCorrupt
+	}
+
+	return dst, nil
+}
+
+func snappyEncodeBlock(dst []byte, src []byte) []byte {
+
+	if len(src) < 16 {
+		return snappyEmitLiteral(dst, src)
+	}
+
+	//保存位置+1，0 表示空
+	var table [1 << snappyTableBits]int32
+
+	literal := 0
+	i := 0
+
+	for i+4 <= len(src) {
+
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := (v * 0x1e35a7bd) >> (32 - snappyTableBits)
+		candidate := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if candidate < 0 || binary.LittleEndian.Uint32(src[candidate:]) != v {
+			i++
+			continue
+		}
+
+		dst = snappyEmitLiteral(dst, src[literal:i])
+
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+
+		dst = snappyEmitCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+
+	return snappyEmitLiteral(dst, src[literal:])
+}
+
+func snappyEmitLiteral(dst []byte, literal []byte) []byte {
+
+	n := len(literal) - 1
+
+	switch {
+	case n < 0:
+		return dst
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+
+	return append(dst, literal...)
+}
+
+func snappyEmitCopy(dst []byte, offset int, length int) []byte {
+
+	for length > 0 {
+
+		n := length
+
+		if n > 64 {
+			n = 64
+		}
+
+		if n >= 4 && n <= 11 && offset < 2048 {
+			dst = append(dst, byte(offset>>8)<<5|byte(n-4)<<2|0x01, byte(offset))
+		} else {
+			dst = append(dst, byte(n-1)<<2|0x02, byte(offset), byte(offset>>8))
+		}
+
+		length -= n
+	}
+
+	return dst
+}
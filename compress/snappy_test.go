@@ -0,0 +1,102 @@
+package compress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestSnappyRoundTrip(t *testing.T) {
+
+	c, ok := Get(Snappy)
+
+	if !ok {
+		t.Fatal("snappy not registered")
+	}
+
+	random := make([]byte, 100000)
+	rand.Read(random)
+
+	tests := [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("hello yar hello yar hello yar hello yar"),
+		bytes.Repeat([]byte("abcdefgh"), 50000),
+		bytes.Repeat([]byte{0}, 300000),
+		random,
+	}
+
+	for _, v := range tests {
+
+		compressed, err := c.Compress(v)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decompressed, err := c.Decompress(compressed)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(v, decompressed) {
+			t.Fatal("round trip mismatch", len(v), len(decompressed))
+		}
+	}
+}
+
+func TestSnappyCorrupt(t *testing.T) {
+
+	c, _ := Get(Snappy)
+
+	tests := [][]byte{
+		{},
+		{0x05, 0x0a},
+		{0x04, 0x01, 0x10},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for _, v := range tests {
+		if _, err := c.Decompress(v); err == nil {
+			t.Fatal("expected error", v)
+		}
+	}
+}
+
+func TestSnappyLimit(t *testing.T) {
+
+	c, _ := Get(Snappy)
+
+	//10 字节的数据声明解压后为 200MB
+	var claim [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(claim[:], 200*1024*1024)
+	src := append(claim[:n], 0x00, 'a', 0xfe, 0x01, 0x00)
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	allocated := stats.TotalAlloc
+
+	if _, err := c.Decompress(src); err == nil {
+		t.Fatal("oversized length accepted")
+	}
+
+	runtime.ReadMemStats(&stats)
+
+	if stats.TotalAlloc-allocated > 1024*1024 {
+		t.Fatal("allocated for the claimed length", stats.TotalAlloc-allocated)
+	}
+
+	//超过调用方的限制
+	compressed, _ := c.Compress(bytes.Repeat([]byte("a"), 1000))
+
+	if _, err := c.(LimitedDecompressor).DecompressLimit(compressed, 999); err == nil {
+		t.Fatal("limit ignored")
+	}
+
+	if _, err := c.(LimitedDecompressor).DecompressLimit(compressed, 1000); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,117 @@
+package yar
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// KeyRing 保存用于数据加密的共享密钥，密钥编号写入头部，便于轮换密钥
+type KeyRing struct {
+	lock sync.RWMutex
+	keys map[uint8][]byte
+}
+
+func NewKeyRing() *KeyRing {
+	k := new(KeyRing)
+	k.keys = make(map[uint8][]byte)
+	return k
+}
+
+// Set 设置编号为 id 的密钥，secret 可以为任意长度，实际使用其 sha256 作为 AES-256 的密钥
+func (k *KeyRing) Set(id uint8, secret []byte) {
+	sum := sha256.Sum256(secret)
+	k.lock.Lock()
+	k.keys[id] = sum[:]
+	k.lock.Unlock()
+}
+
+func (k *KeyRing) Delete(id uint8) {
+	k.lock.Lock()
+	delete(k.keys, id)
+	k.lock.Unlock()
+}
+
+func (k *KeyRing) Get(id uint8) ([]byte, bool) {
+	k.lock.RLock()
+	key, ok := k.keys[id]
+	k.lock.RUnlock()
+	return key, ok
+}
+
+// 未设置 KeyRing 时，EncryptPrivateKey 作为编号为 0 的密钥
+func encryptKey(opt *Opt, id uint8) ([]byte, bool) {
+
+	if opt.KeyRing != nil {
+		return opt.KeyRing.Get(id)
+	}
+
+	if id == 0 && len(opt.EncryptPrivateKey) > 0 {
+		sum := sha256.Sum256([]byte(opt.EncryptPrivateKey))
+		return sum[:], true
+	}
+
+	return nil, false
+}
+
+// 以 AES-GCM 加密，输出为 12 字节随机 nonce + 密文，头部的 Id 与 MagicNumber 作为附加数据参与认证
+func encryptBody(header *Header, opt *Opt, data []byte) ([]byte, error) {
+
+	aead, err := newAEAD(header, opt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, additionalData(header)), nil
+}
+
+func decryptBody(header *Header, opt *Opt, data []byte) ([]byte, error) {
+
+	aead, err := newAEAD(header, opt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted body too short")
+	}
+
+	nonce := data[:aead.NonceSize()]
+	return aead.Open(nil, nonce, data[aead.NonceSize():], additionalData(header))
+}
+
+func newAEAD(header *Header, opt *Opt) (cipher.AEAD, error) {
+
+	key, ok := encryptKey(opt, header.KeyId())
+
+	if !ok {
+		return nil, errors.New("encrypt key not found")
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func additionalData(header *Header) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint32(data[0:], header.Id)
+	binary.BigEndian.PutUint32(data[4:], uint32(header.MagicNumber))
+	return data
+}
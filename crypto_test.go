@@ -0,0 +1,47 @@
+package yar
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptBody(t *testing.T) {
+
+	opt := NewOpt()
+	opt.KeyRing = NewKeyRing()
+	opt.KeyRing.Set(3, []byte("secret"))
+
+	header := NewHeader()
+	header.Id = 42
+	header.Encrypt = 1
+	header.SetKeyId(3)
+	header.SetFlag(FlagChecksum)
+
+	data := []byte(`{"i":42,"m":"Echo","p":["hello"]}`)
+
+	encoded, err := EncodeBody(header, opt, data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(encoded, data) {
+		t.Fatal("body is not encrypted")
+	}
+
+	decoded, err := DecodeBody(header, opt, encoded)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("decoded %q", decoded)
+	}
+
+	header.SetKeyId(4)
+
+	if _, err = DecodeBody(header, opt, encoded); err == nil {
+		t.Fatal("expected error with unknown key id")
+	}
+}
@@ -23,6 +23,12 @@ const (
 	ErrorResponse ErrorEnum = 8
 	//返回数据错误
 	ErrorRequest ErrorEnum = 9
+	//返回数据的 MagicNumber 不匹配
+	ErrorMagicNumber ErrorEnum = 10
+	//返回数据的 Id 与请求不一致
+	ErrorResponseId ErrorEnum = 11
+	//返回数据的 BodyLength 不合法
+	ErrorBodyLength ErrorEnum = 12
 )
 
 func (e ErrorEnum) String() string {
@@ -46,24 +52,51 @@ func (e ErrorEnum) String() string {
 		return "Response Error"
 	case ErrorRequest:
 		return "Request Error"
+	case ErrorMagicNumber:
+		return "MagicNumber Error"
+	case ErrorResponseId:
+		return "Response Id Error"
+	case ErrorBodyLength:
+		return "Body Length Error"
 	}
 
 	return "Unknow Error"
 }
 
 type Error struct {
-	t ErrorEnum
-	m string
+	t      ErrorEnum
+	m      string
+	status ErrorType
 }
 
 func NewError(t ErrorEnum, m string) *Error {
 	return &Error{t: t, m: m}
 }
 
+// NewStatusError 服务端返回非 ERR_OKEY 状态时使用，保留返回的状态码
+func NewStatusError(status ErrorType, m string) *Error {
+	return &Error{t: ErrorResponse, m: m, status: status}
+}
+
 func (e *Error) String() string {
+
+	if e.status != ERR_OKEY {
+		return fmt.Sprintf("[%s] %s %s", e.t, e.status, e.m)
+	}
+
 	return fmt.Sprintf("[%s] %s", e.t, e.m)
 }
 
 func (e *Error) Assert(t ErrorEnum) bool {
 	return e.t == t
 }
+
+// Status 服务端返回的状态码，非服务端返回的错误为 ERR_OKEY
+func (e *Error) Status() ErrorType {
+	return e.status
+}
+
+// Retriable 网络错误及服务端返回可重试状态的错误可以重试
+func (e *Error) Retriable() bool {
+	return e.t == ErrorNetwork || e.status.Retriable()
+}
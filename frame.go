@@ -0,0 +1,228 @@
+package yar
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var ErrFrameTooLarge = errors.New("frame exceeds max body length")
+
+// ReadFrame 从 r 中读取一个完整的数据帧，返回包含头部在内的全部字节
+// 非分块模式读取 BodyLength 指定的长度，分块模式读取到结束分块为止
+// 头部按 MagicNumber 识别布局，连接在帧的边界上被关闭时返回 io.EOF
+func ReadFrame(r io.Reader) ([]byte, *Header, error) {
+	frame, _, header, err := readFrame(r)
+	return frame, header, err
+}
+
+// ReadFrameBody 与 ReadFrame 相同，返回的数据不包含头部
+func ReadFrameBody(r io.Reader) ([]byte, *Header, error) {
+
+	frame, n, header, err := readFrame(r)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return frame[n:], header, nil
+}
+
+func readFrame(r io.Reader) ([]byte, int, *Header, error) {
+
+	headerBuffer, header, err := ReadHeader(r)
+
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	frame := make([]byte, len(headerBuffer)+int(header.BodyLength)-PackagerLength)
+	copy(frame, headerBuffer)
+
+	if _, err = io.ReadFull(r, frame[len(headerBuffer):]); err != nil {
+		return nil, 0, nil, err
+	}
+
+	if !header.HasFlag(FlagChunked) {
+		return frame, len(headerBuffer), header, nil
+	}
+
+	var length [4]byte
+
+	for {
+
+		if _, err = io.ReadFull(r, length[:]); err != nil {
+			return nil, 0, nil, err
+		}
+
+		frame = append(frame, length[:]...)
+		n := binary.BigEndian.Uint32(length[:])
+
+		if n == 0 {
+			return frame, len(headerBuffer), header, nil
+		}
+
+		if uint64(len(frame))+uint64(n) > uint64(MaxBodyLength) {
+			return nil, 0, nil, ErrFrameTooLarge
+		}
+
+		start := len(frame)
+		frame = append(frame, make([]byte, n)...)
+
+		if _, err = io.ReadFull(r, frame[start:]); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+}
+
+// FrameWriter 将数据拆分为多个帧写出，除最后一帧外均带有 FlagContinuation，所有帧使用同一个 Id
+// 数据不超过一个分段时只输出一个普通的帧。续帧模式下不进行压缩与校验
+type FrameWriter struct {
+	w       io.Writer
+	header  Header
+	segment []byte
+	closed  bool
+}
+
+func NewFrameWriter(w io.Writer, header *Header, segmentSize int) *FrameWriter {
+
+	if segmentSize <= 0 || uint32(segmentSize) > MaxBodyLength-PackagerLength {
+		segmentSize = int(MaxBodyLength - PackagerLength)
+	}
+
+	fw := new(FrameWriter)
+	fw.w = w
+	fw.header = *header
+	fw.header.ClearFlag(FlagChunked | FlagChecksum | FlagContinuation | FlagTrailer | FlagSigned)
+	fw.header.SetCompression(0)
+	fw.segment = make([]byte, 0, segmentSize)
+	return fw
+}
+
+func (fw *FrameWriter) Write(data []byte) (n int, err error) {
+
+	if fw.closed {
+		return 0, errors.New("write on closed frame writer")
+	}
+
+	for len(data) > 0 {
+
+		//分段已满且仍有数据，说明还有后续帧
+		if len(fw.segment) == cap(fw.segment) {
+			if err = fw.writeFrame(true); err != nil {
+				return n, err
+			}
+		}
+
+		free := cap(fw.segment) - len(fw.segment)
+
+		if free > len(data) {
+			free = len(data)
+		}
+
+		fw.segment = append(fw.segment, data[:free]...)
+		data = data[free:]
+		n += free
+	}
+
+	return n, nil
+}
+
+// Close 写出最后一帧，不会关闭下层的 Writer
+func (fw *FrameWriter) Close() error {
+
+	if fw.closed {
+		return nil
+	}
+
+	fw.closed = true
+	return fw.writeFrame(false)
+}
+
+func (fw *FrameWriter) writeFrame(continuation bool) error {
+
+	header := fw.header
+	header.BodyLength = uint32(PackagerLength + len(fw.segment))
+
+	if continuation {
+		header.SetFlag(FlagContinuation)
+	}
+
+	if _, err := fw.w.Write(header.Encode()); err != nil {
+		return err
+	}
+
+	if len(fw.segment) > 0 {
+		if _, err := fw.w.Write(fw.segment); err != nil {
+			return err
+		}
+	}
+
+	fw.segment = fw.segment[0:0]
+	return nil
+}
+
+// FrameReader 将首帧及其后续帧的数据拼接为一个连续的数据流
+// r 需要位于首帧数据的起始处(首帧头部已被读取)
+type FrameReader struct {
+	r      io.Reader
+	first  *Header
+	header *Header
+	remain uint32
+}
+
+func NewFrameReader(r io.Reader, first *Header) *FrameReader {
+	fr := new(FrameReader)
+	fr.r = r
+	fr.first = first
+	fr.header = first
+	fr.remain = first.BodyLength - PackagerLength
+	return fr
+}
+
+func (fr *FrameReader) Read(buffer []byte) (n int, err error) {
+
+	for fr.remain == 0 {
+
+		if !fr.header.HasFlag(FlagContinuation) {
+			return 0, io.EOF
+		}
+
+		headerBuffer := make([]byte, HeaderLength)
+
+		if _, err = io.ReadFull(fr.r, headerBuffer); err != nil {
+			return 0, errors.New("read continuation frame error:" + err.Error())
+		}
+
+		next, parseErr := ParseHeader(headerBuffer)
+
+		if parseErr != nil {
+			return 0, parseErr
+		}
+
+		if next.Id != fr.first.Id || next.MagicNumber != fr.first.MagicNumber {
+			return 0, errors.New("continuation frame does not belong to the same request")
+		}
+
+		//首帧的头部由调用方持有，后续帧的头部用完即放回池中
+		if fr.header != fr.first {
+			ReleaseHeader(fr.header)
+		}
+
+		fr.header = next
+		fr.remain = next.BodyLength - PackagerLength
+	}
+
+	if uint32(len(buffer)) > fr.remain {
+		buffer = buffer[:fr.remain]
+	}
+
+	n, err = fr.r.Read(buffer)
+	fr.remain -= uint32(n)
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
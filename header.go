@@ -3,17 +3,32 @@ package yar
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 )
 
 const (
 	ProtocolLength = 82
 	PackagerLength = 8
+	//HeaderLength 协议头与打包协议名的总长度
+	HeaderLength = ProtocolLength + PackagerLength
+)
+
+// MaxBodyLength 允许的最大 BodyLength，超出则视为非法数据
+var MaxBodyLength uint32 = 128 * 1024 * 1024
+
+var (
+	ErrHeaderTooShort      = errors.New("header too short")
+	ErrBodyLengthUnderflow = errors.New("body length less than packager length")
+	ErrBodyLengthOverflow  = errors.New("body length exceeds max body length")
 )
 
 type ErrorType int
 
+// Magic 头部中的 MagicNumber
+type Magic uint32
+
 const (
-	MagicNumber = 0x80DFEC60
+	MagicNumber Magic = 0x80DFEC60
 )
 
 const (
@@ -26,18 +41,76 @@ const (
 	ERR_FORBIDDEN      ErrorType = 0x20
 	ERR_EXCEPTION      ErrorType = 0x40
 	ERR_EMPTY_RESPONSE ErrorType = 0x80
+	//以下为 php-yar 之外的扩展状态，php-yar 客户端会将其视为一般错误
+	//ERR_AUTH 认证失败，如 Token 错误、重放校验失败
+	ERR_AUTH ErrorType = 0x100
+	//ERR_THROTTLED 服务端限流，稍后可重试
+	ERR_THROTTLED ErrorType = 0x200
+	//ERR_NOT_FOUND 调用的方法不存在
+	ERR_NOT_FOUND ErrorType = 0x400
+	//ERR_INVALID_PARAMS 参数个数或类型不匹配
+	ERR_INVALID_PARAMS ErrorType = 0x800
+	//ERR_INTERNAL 处理方法内部错误
+	ERR_INTERNAL ErrorType = 0x1000
+)
+
+// Retriable 该状态的请求是否可以安全重试
+func (t ErrorType) Retriable() bool {
+	return t == ERR_THROTTLED || t == ERR_TRANSPORT
+}
+
+// ForVersion 对端按 version 协商时使用的状态，原始协议的 php-yar 不认识扩展状态，返回 ERR_EMPTY_RESPONSE
+func (t ErrorType) ForVersion(version uint16) ErrorType {
+
+	if version < ProtocolVersionFlags && t > ERR_EMPTY_RESPONSE {
+		return ERR_EMPTY_RESPONSE
+	}
+
+	return t
+}
+
+// Reserved 字段按位作为标志使用
+const (
+	//FlagChunked 数据以分块形式传输，头部中的 BodyLength 只包含打包协议名的长度
+	FlagChunked uint32 = 0x00000001
+	//FlagChecksum 数据末尾追加 4 字节大端的 CRC32(IEEE) 校验值，BodyLength 包含该校验值
+	//Reserved 字段已用作标志位，因此校验值放在数据末尾而不是头部
+	FlagChecksum uint32 = 0x00000002
+	//FlagPersistent 请求方希望在返回后保持连接，返回中带有该标志表示服务端同意，仅对 tcp/unix 连接有意义
+	FlagPersistent uint32 = 0x00000004
+	//FlagContinuation 该帧之后还有属于同一 Id 的后续帧，各帧的数据依次拼接为完整的数据，需要协议版本 2
+	FlagContinuation uint32 = 0x00000008
+	//FlagTrailer 请求方希望服务端在返回数据之后追加 Trailer，返回中带有该标志表示数据末尾带有 Trailer
+	//Trailer 位于校验值之后，BodyLength 包含 Trailer 的长度，分块与续帧模式下不返回
+	FlagTrailer uint32 = 0x00000010
+	//FlagSigned Token 为请求(返回)的 HMAC 签名，见 SignHeader
+	FlagSigned uint32 = 0x00000020
+	//FlagMultiplex 请求方在同一连接上并发发送多个请求，按头部的 Id 对应返回，返回的顺序不固定
+	//返回中带有该标志表示服务端同意，需要同时带有 FlagPersistent，续帧与分块模式下不可用
+	FlagMultiplex uint32 = 0x00000040
+	//FlagPing 探测连接是否可用的空帧，数据只有打包协议名；服务端不调用任何方法，返回带有该标志的空帧(pong)
+	//pong 带有 FlagPersistent 表示连接可以继续使用
+	FlagPing uint32 = 0x00000080
+	//FlagCompressMask 第 8-11 位为数据的压缩算法编号，0 表示未压缩
+	FlagCompressMask  uint32 = 0x00000F00
+	FlagCompressShift uint32 = 8
+	//FlagKeyIdMask 第 16-23 位为加密密钥的编号，是否加密由 Encrypt 字段表示
+	FlagKeyIdMask  uint32 = 0x00FF0000
+	FlagKeyIdShift uint32 = 16
 )
 
 type Header struct {
 	Id          uint32
 	Version     uint16
-	MagicNumber uint32
+	MagicNumber Magic
 	Reserved    uint32
 	Provider    [28]byte
 	Encrypt     uint32
 	Token       [32]byte
 	BodyLength  uint32
 	Packager    [8]byte
+	//Layout 头部在字节流中的布局，为空表示 DefaultLayout，不参与编码
+	Layout *Layout
 }
 
 func NewHeader() *Header {
@@ -55,27 +128,169 @@ func NewHeaderWithBytes(payload *bytes.Buffer) *Header {
 	return p
 }
 
+// 头部各字段在字节流中的偏移，与 php-yar 的 yar_header_t(按 1 字节对齐，网络字节序)保持一致
+// php-yar 中 provider 为 32 字节，这里将其末尾 4 字节用作 Encrypt
+const (
+	offsetId          = 0
+	offsetVersion     = 4
+	offsetMagicNumber = 6
+	offsetReserved    = 10
+	offsetProvider    = 14
+	offsetEncrypt     = 42
+	offsetToken       = 46
+	offsetBodyLength  = 78
+	offsetPackager    = 82
+)
+
+// Init 从 payload 中读取头部，数据不足时返回 false
 func (self *Header) Init(payload *bytes.Buffer) bool {
 
-	binary.Read(payload, binary.BigEndian, &self.Id)
-	binary.Read(payload, binary.BigEndian, &self.Version)
-	binary.Read(payload, binary.BigEndian, &self.MagicNumber)
-	binary.Read(payload, binary.BigEndian, &self.Reserved)
-	binary.Read(payload, binary.BigEndian, &self.Provider)
-	binary.Read(payload, binary.BigEndian, &self.Encrypt)
-	binary.Read(payload, binary.BigEndian, &self.Token)
-	binary.Read(payload, binary.BigEndian, &self.BodyLength)
-	binary.Read(payload, binary.BigEndian, &self.Packager)
+	if payload.Len() < HeaderLength {
+		return false
+	}
+
+	return self.Decode(payload.Next(HeaderLength))
+}
+
+// Decode 按固定偏移与大端字节序解析头部，不依赖结构体字段顺序
+func (self *Header) Decode(data []byte) bool {
+
+	if len(data) < HeaderLength {
+		return false
+	}
+
+	self.Id = binary.BigEndian.Uint32(data[offsetId:])
+	self.Version = binary.BigEndian.Uint16(data[offsetVersion:])
+	self.MagicNumber = Magic(binary.BigEndian.Uint32(data[offsetMagicNumber:]))
+	self.Reserved = binary.BigEndian.Uint32(data[offsetReserved:])
+	copy(self.Provider[:], data[offsetProvider:offsetEncrypt])
+	self.Encrypt = binary.BigEndian.Uint32(data[offsetEncrypt:])
+	copy(self.Token[:], data[offsetToken:offsetBodyLength])
+	self.BodyLength = binary.BigEndian.Uint32(data[offsetBodyLength:])
+	copy(self.Packager[:], data[offsetPackager:HeaderLength])
 	return true
 }
 
-func (self *Header) Bytes() *bytes.Buffer {
+// Encode 按固定偏移与大端字节序输出 HeaderLength 字节的头部
+func (self *Header) Encode() []byte {
+
+	data := make([]byte, HeaderLength)
+
+	binary.BigEndian.PutUint32(data[offsetId:], self.Id)
+	binary.BigEndian.PutUint16(data[offsetVersion:], self.Version)
+	binary.BigEndian.PutUint32(data[offsetMagicNumber:], uint32(self.MagicNumber))
+	binary.BigEndian.PutUint32(data[offsetReserved:], self.Reserved)
+	copy(data[offsetProvider:offsetEncrypt], self.Provider[:])
+	binary.BigEndian.PutUint32(data[offsetEncrypt:], self.Encrypt)
+	copy(data[offsetToken:offsetBodyLength], self.Token[:])
+	binary.BigEndian.PutUint32(data[offsetBodyLength:], self.BodyLength)
+	copy(data[offsetPackager:HeaderLength], self.Packager[:])
+	return data
+}
+
+// ParseHeader 解析并校验头部，data 至少需要包含 HeaderLength 个字节
+// 对 BodyLength 做上下界检查，调用方可以放心地使用 BodyLength-PackagerLength
+func ParseHeader(data []byte) (*Header, error) {
+
+	if len(data) < HeaderLength {
+		return nil, ErrHeaderTooShort
+	}
+
+	header := AcquireHeader()
+	header.Decode(data)
+
+	if err := checkBodyLength(header); err != nil {
+		ReleaseHeader(header)
+		return nil, err
+	}
+
+	return header, nil
+}
+
+func checkBodyLength(header *Header) error {
+
+	if header.BodyLength < PackagerLength {
+		return ErrBodyLengthUnderflow
+	}
+
+	if header.BodyLength > MaxBodyLength {
+		return ErrBodyLengthOverflow
+	}
+
+	return nil
+}
+
+// 以下方法将字符串复制到定长字段中，超出部分被截断，不足部分以 0 填充
+
+func (self *Header) SetProvider(provider string) {
+	self.Provider = [28]byte{}
+	copy(self.Provider[:], provider)
+}
+
+func (self *Header) SetToken(token string) {
+	self.Token = [32]byte{}
+	copy(self.Token[:], token)
+}
+
+func (self *Header) SetPackager(name string) {
+	self.Packager = [8]byte{}
+	copy(self.Packager[:], name)
+}
+
+func (self *Header) ProviderString() string {
+	return fixedString(self.Provider[:])
+}
+
+func (self *Header) TokenString() string {
+	return fixedString(self.Token[:])
+}
+
+func (self *Header) PackagerString() string {
+	return fixedString(self.Packager[:])
+}
+
+func fixedString(field []byte) string {
+	return string(bytes.TrimRight(field, "\x00"))
+}
+
+func (self *Header) HasFlag(flag uint32) bool {
+	return self.Reserved&flag == flag
+}
+
+func (self *Header) SetFlag(flag uint32) {
+	self.Reserved |= flag
+}
 
-	buffer := new(bytes.Buffer)
-	err := binary.Write(buffer, binary.BigEndian, self)
+func (self *Header) ClearFlag(flag uint32) {
+	self.Reserved &^= flag
+}
+
+func (self *Header) Compression() uint8 {
+	return uint8((self.Reserved & FlagCompressMask) >> FlagCompressShift)
+}
+
+func (self *Header) SetCompression(id uint8) {
+	self.Reserved = (self.Reserved &^ FlagCompressMask) | (uint32(id)<<FlagCompressShift)&FlagCompressMask
+}
 
-	if err != nil {
-		return nil
+func (self *Header) KeyId() uint8 {
+	return uint8((self.Reserved & FlagKeyIdMask) >> FlagKeyIdShift)
+}
+
+func (self *Header) SetKeyId(id uint8) {
+	self.Reserved = (self.Reserved &^ FlagKeyIdMask) | uint32(id)<<FlagKeyIdShift
+}
+
+// WireBytes 按 Layout 输出头部
+func (self *Header) WireBytes() []byte {
+
+	if self.Layout == nil {
+		return self.Encode()
 	}
-	return buffer
+
+	return self.Layout.Encode(self)
+}
+
+func (self *Header) Bytes() *bytes.Buffer {
+	return bytes.NewBuffer(self.Encode())
 }
@@ -0,0 +1,164 @@
+package yar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+
+	h := NewHeader()
+	h.Id = 1234
+	h.BodyLength = PackagerLength + 10
+	h.SetPackager("json")
+
+	parsed, err := ParseHeader(h.Bytes().Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *parsed != *h {
+		t.Fatal("parsed header mismatch", parsed, h)
+	}
+
+	if _, err := ParseHeader(h.Bytes().Bytes()[:HeaderLength-1]); err != ErrHeaderTooShort {
+		t.Fatal("expected ErrHeaderTooShort", err)
+	}
+
+	h.BodyLength = PackagerLength - 1
+	if _, err := ParseHeader(h.Bytes().Bytes()); err != ErrBodyLengthUnderflow {
+		t.Fatal("expected ErrBodyLengthUnderflow", err)
+	}
+
+	h.BodyLength = MaxBodyLength + 1
+	if _, err := ParseHeader(h.Bytes().Bytes()); err != ErrBodyLengthOverflow {
+		t.Fatal("expected ErrBodyLengthOverflow", err)
+	}
+}
+
+func FuzzParseHeader(f *testing.F) {
+
+	h := NewHeader()
+	h.BodyLength = PackagerLength
+	f.Add(h.Bytes().Bytes())
+	f.Add([]byte{})
+	f.Add(bytes.Repeat([]byte{0xff}, HeaderLength))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+
+		header, err := ParseHeader(data)
+
+		if err != nil {
+			return
+		}
+
+		if header.BodyLength < PackagerLength || header.BodyLength > MaxBodyLength {
+			t.Fatal("body length not checked", header.BodyLength)
+		}
+
+		if !bytes.Equal(header.Bytes().Bytes(), data[:HeaderLength]) {
+			t.Fatal("round trip mismatch")
+		}
+	})
+}
+
+// 按照 php-yar yar_protocol.h 中 yar_header_t 的布局(1 字节对齐，网络字节序)手工构造的头部
+// 该样例并非从 php-yar 抓取，只能发现与上述布局的偏差，不能替代与 php-yar 的实际互通测试
+// id=0x01020304 version=1 magic=0x80DFEC60 reserved=0 provider="php" token="tk" body_len=0x14 packager="JSON"
+var goldenHeader = []byte{
+	0x01, 0x02, 0x03, 0x04, //id
+	0x00, 0x01, //version
+	0x80, 0xdf, 0xec, 0x60, //magic_num
+	0x00, 0x00, 0x00, 0x00, //reserved
+	'p', 'h', 'p', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, //provider[0:28]
+	0x00, 0x00, 0x00, 0x00, //provider[28:32]，即 Encrypt
+	't', 'k', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, //token
+	0x00, 0x00, 0x00, 0x14, //body_len
+	'J', 'S', 'O', 'N', 0, 0, 0, 0, //packager，php-yar 中位于 body 的起始处
+}
+
+func TestHeaderGolden(t *testing.T) {
+
+	if len(goldenHeader) != HeaderLength {
+		t.Fatal("golden header length", len(goldenHeader))
+	}
+
+	h := NewHeader()
+	h.Id = 0x01020304
+	h.Version = 1
+	h.SetProvider("php")
+	h.SetToken("tk")
+	h.BodyLength = 0x14
+	h.SetPackager("JSON")
+
+	if !bytes.Equal(h.Encode(), goldenHeader) {
+		t.Fatalf("encode mismatch\n%x\n%x", h.Encode(), goldenHeader)
+	}
+
+	decoded := NewHeader()
+
+	if !decoded.Decode(goldenHeader) || *decoded != *h {
+		t.Fatal("decode mismatch", decoded)
+	}
+}
+
+func TestStatusClassification(t *testing.T) {
+
+	tests := []struct {
+		err       *Error
+		retriable bool
+		status    ErrorType
+	}{
+		{NewError(ErrorNetwork, "timeout"), true, ERR_OKEY},
+		{NewError(ErrorPackager, "bad data"), false, ERR_OKEY},
+		{NewStatusError(ERR_THROTTLED, "busy"), true, ERR_THROTTLED},
+		{NewStatusError(ERR_TRANSPORT, "upstream"), true, ERR_TRANSPORT},
+		{NewStatusError(ERR_NOT_FOUND, "missing"), false, ERR_NOT_FOUND},
+		{NewStatusError(ERR_INVALID_PARAMS, "params"), false, ERR_INVALID_PARAMS},
+		{NewStatusError(ERR_INTERNAL, "panic"), false, ERR_INTERNAL},
+		{NewStatusError(ERR_EMPTY_RESPONSE, "legacy"), false, ERR_EMPTY_RESPONSE},
+	}
+
+	for _, test := range tests {
+		if test.err.Retriable() != test.retriable || test.err.Status() != test.status {
+			t.Fatal(test.err.String(), test.err.Retriable(), test.err.Status())
+		}
+	}
+
+	//原始协议的对端只收到 php-yar 定义的状态
+	for _, status := range []ErrorType{ERR_AUTH, ERR_THROTTLED, ERR_NOT_FOUND, ERR_INVALID_PARAMS, ERR_INTERNAL} {
+		if status.ForVersion(ProtocolVersionLegacy) != ERR_EMPTY_RESPONSE || status.ForVersion(ProtocolVersionFlags) != status {
+			t.Fatal("unexpected status for version", status)
+		}
+	}
+
+	if ERR_EXCEPTION.ForVersion(ProtocolVersionLegacy) != ERR_EXCEPTION {
+		t.Fatal("php-yar status rewritten")
+	}
+}
+
+func TestFixedFields(t *testing.T) {
+
+	header := NewHeader()
+	header.SetProvider("gateway")
+	header.SetToken(strings.Repeat("t", 40))
+	header.SetPackager("json")
+
+	if header.ProviderString() != "gateway" || header.PackagerString() != "json" {
+		t.Fatal(header.ProviderString(), header.PackagerString())
+	}
+
+	//超出定长字段的部分被截断
+	if header.TokenString() != strings.Repeat("t", 32) {
+		t.Fatal(header.TokenString())
+	}
+
+	//较短的值覆盖时不残留之前的内容
+	header.SetProvider("g")
+
+	if header.ProviderString() != "g" || header.Provider[1] != 0 {
+		t.Fatal(header.Provider)
+	}
+}
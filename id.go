@@ -0,0 +1,63 @@
+package yar
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// IdGenerator 生成请求 Id，实现需要保证并发安全
+type IdGenerator interface {
+	NextId() uint32
+}
+
+type IdGeneratorFunc func() uint32
+
+func (f IdGeneratorFunc) NextId() uint32 {
+	return f()
+}
+
+// CounterIdGenerator 以随机数为基数原子递增生成 Id
+// 不同进程的基数不同，同一进程内连续 2^32 个 Id 不会重复，超出后回绕(uint32 溢出)从基数重新开始
+type CounterIdGenerator struct {
+	counter uint32
+}
+
+func NewCounterIdGenerator() *CounterIdGenerator {
+	g := new(CounterIdGenerator)
+	g.counter = randomBase()
+	return g
+}
+
+func (g *CounterIdGenerator) NextId() uint32 {
+	return atomic.AddUint32(&g.counter, 1)
+}
+
+func randomBase() uint32 {
+
+	var b [4]byte
+
+	if _, err := crand.Read(b[:]); err != nil {
+		return uint32(rand.New(rand.NewSource(time.Now().UnixNano())).Int63())
+	}
+
+	return binary.BigEndian.Uint32(b[:])
+}
+
+var idGenerator IdGenerator = NewCounterIdGenerator()
+
+// SetIdGenerator 替换全局的 Id 生成器，应在初始化阶段调用，传入 nil 恢复默认实现
+func SetIdGenerator(g IdGenerator) {
+
+	if g == nil {
+		g = NewCounterIdGenerator()
+	}
+
+	idGenerator = g
+}
+
+func NextId() uint32 {
+	return idGenerator.NextId()
+}
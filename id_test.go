@@ -0,0 +1,68 @@
+package yar
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounterIdUnique(t *testing.T) {
+
+	const (
+		workers   = 8
+		perWorker = 10000
+	)
+
+	ids := make(chan uint32, workers*perWorker)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < perWorker; n++ {
+				ids <- NextId()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[uint32]bool, workers*perWorker)
+
+	for id := range ids {
+		if seen[id] {
+			t.Fatal("duplicate id", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSetIdGenerator(t *testing.T) {
+
+	next := uint32(100)
+	SetIdGenerator(IdGeneratorFunc(func() uint32 {
+		next++
+		return next
+	}))
+	defer SetIdGenerator(nil)
+
+	if r := NewRequest(); r.Id != 101 {
+		t.Fatal(r.Id)
+	}
+
+	//池中没有对象时创建对象同样会取得一个 Id，最后取得的 Id 为请求的 Id
+	r := AcquireRequest()
+	defer ReleaseRequest(r)
+
+	if r.Id != next {
+		t.Fatal(r.Id, next)
+	}
+
+	//恢复默认实现后不再使用自定义的生成器
+	SetIdGenerator(nil)
+
+	if id := NextId(); id == next+1 {
+		t.Fatal("custom generator still in use")
+	}
+}
@@ -0,0 +1,140 @@
+package yar
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// Layout 描述一种头部在字节流中的布局，用于与早期或修改过的 yar 实现通信
+// 所有布局的 Id、Version、MagicNumber 都位于头部起始的 LayoutPrefixLength 个字节中，
+// 读取时按 MagicNumber 识别布局。Length 包含打包协议名，BodyLength 的含义保持不变
+// 内置的 DefaultLayout 与 PhpLayout 使用相同的 MagicNumber，不参与自动识别，读取时都按 DefaultLayout 解析；
+// 自动识别只用于注册了其它 MagicNumber 的布局
+type Layout struct {
+	Name        string
+	MagicNumber Magic
+	Length      int
+	Encode      func(header *Header) []byte
+	Decode      func(data []byte, header *Header)
+}
+
+const LayoutPrefixLength = offsetMagicNumber + 4
+
+// DefaultLayout 当前使用的布局，provider 末尾 4 字节用作 Encrypt
+var DefaultLayout = &Layout{
+	Name:        "yar",
+	MagicNumber: MagicNumber,
+	Length:      HeaderLength,
+	Encode:      (*Header).Encode,
+	Decode:      func(data []byte, header *Header) { header.Decode(data) },
+}
+
+// PhpLayout php-yar 的原始布局，provider 为 32 字节，不包含 Encrypt
+// MagicNumber 与 DefaultLayout 相同，不参与自动识别，发送时需要通过 Opt.Layout 指定
+var PhpLayout = &Layout{
+	Name:        "php",
+	MagicNumber: MagicNumber,
+	Length:      HeaderLength,
+	Encode: func(header *Header) []byte {
+		data := header.Encode()
+		binary.BigEndian.PutUint32(data[offsetEncrypt:], 0)
+		return data
+	},
+	Decode: func(data []byte, header *Header) {
+		header.Decode(data)
+		header.Encrypt = 0
+	},
+}
+
+var layouts = struct {
+	sync.RWMutex
+	byName  map[string]*Layout
+	byMagic map[Magic]*Layout
+}{byName: make(map[string]*Layout), byMagic: make(map[Magic]*Layout)}
+
+// 内置布局只按名称注册
+func init() {
+	layouts.byName[DefaultLayout.Name] = DefaultLayout
+	layouts.byName[PhpLayout.Name] = PhpLayout
+}
+
+// RegisterLayout 注册布局，同一 MagicNumber 以先注册的布局用于自动识别
+// MagicNumber 与内置布局相同的布局只能通过名称指定，不参与自动识别
+func RegisterLayout(layout *Layout) {
+
+	layouts.Lock()
+	defer layouts.Unlock()
+
+	layouts.byName[layout.Name] = layout
+
+	if layout.MagicNumber == MagicNumber {
+		return
+	}
+
+	if _, ok := layouts.byMagic[layout.MagicNumber]; !ok {
+		layouts.byMagic[layout.MagicNumber] = layout
+	}
+}
+
+func LookupLayout(name string) (*Layout, bool) {
+
+	if len(name) < 1 {
+		return DefaultLayout, true
+	}
+
+	layouts.RLock()
+	layout, ok := layouts.byName[name]
+	layouts.RUnlock()
+	return layout, ok
+}
+
+// DetectLayout 按 MagicNumber 识别布局，未知的 MagicNumber 及内置布局的 MagicNumber 返回 DefaultLayout
+func DetectLayout(magic Magic) *Layout {
+
+	layouts.RLock()
+	layout, ok := layouts.byMagic[magic]
+	layouts.RUnlock()
+
+	if !ok {
+		return DefaultLayout
+	}
+
+	return layout
+}
+
+// ReadHeader 从 r 中读取头部，按 MagicNumber 识别布局，返回头部的原始字节
+// 在头部的起始处遇到连接关闭时返回 io.EOF
+func ReadHeader(r io.Reader) ([]byte, *Header, error) {
+
+	prefix := make([]byte, LayoutPrefixLength)
+
+	if n, err := io.ReadFull(r, prefix); err != nil {
+
+		if n == 0 && err == io.EOF {
+			return nil, nil, io.EOF
+		}
+
+		return nil, nil, err
+	}
+
+	layout := DetectLayout(Magic(binary.BigEndian.Uint32(prefix[offsetMagicNumber:])))
+
+	data := make([]byte, layout.Length)
+	copy(data, prefix)
+
+	if _, err := io.ReadFull(r, data[LayoutPrefixLength:]); err != nil {
+		return nil, nil, err
+	}
+
+	header := AcquireHeader()
+	layout.Decode(data, header)
+	header.Layout = layout
+
+	if err := checkBodyLength(header); err != nil {
+		ReleaseHeader(header)
+		return nil, nil, err
+	}
+
+	return data, header, nil
+}
@@ -0,0 +1,82 @@
+package yar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestReadFrameDetectsLayout(t *testing.T) {
+
+	//不带 token 的布局，头部共 58 字节
+	short := &Layout{
+		Name:        "test-short",
+		MagicNumber: 0x80DFEC61,
+		Length:      58,
+		Encode: func(header *Header) []byte {
+			data := make([]byte, 58)
+			copy(data, header.Encode()[:offsetToken])
+			binary.BigEndian.PutUint32(data[6:], 0x80DFEC61)
+			binary.BigEndian.PutUint32(data[46:], header.BodyLength)
+			copy(data[50:], header.Packager[:])
+			return data
+		},
+		Decode: func(data []byte, header *Header) {
+			full := make([]byte, HeaderLength)
+			copy(full, data[:offsetToken])
+			copy(full[offsetBodyLength:], data[46:])
+			header.Decode(full)
+		},
+	}
+	RegisterLayout(short)
+
+	header := NewHeader()
+	header.Id = 9
+	header.SetPackager("json")
+	header.BodyLength = PackagerLength + 4
+
+	stream := new(bytes.Buffer)
+	stream.Write(short.Encode(header))
+	stream.WriteString("body")
+	stream.Write(header.Encode())
+	stream.WriteString("body")
+
+	first, firstHeader, err := ReadFrame(stream)
+
+	if err != nil || len(first) != 62 || firstHeader.MagicNumber != 0x80DFEC61 || firstHeader.Id != 9 {
+		t.Fatalf("short layout: %v %d %+v", err, len(first), firstHeader)
+	}
+
+	second, secondHeader, err := ReadFrame(stream)
+
+	if err != nil || len(second) != HeaderLength+4 || secondHeader.MagicNumber != MagicNumber {
+		t.Fatalf("default layout: %v %d", err, len(second))
+	}
+
+	if _, _, err = ReadFrame(stream); err != io.EOF {
+		t.Fatal("expected EOF", err)
+	}
+}
+
+func TestBuiltinLayoutDetection(t *testing.T) {
+
+	//内置布局的 MagicNumber 相同，只能按名称指定
+	if DetectLayout(MagicNumber) != DefaultLayout {
+		t.Fatal("built-in magic number should read as the default layout")
+	}
+
+	if layout, ok := LookupLayout("php"); !ok || layout != PhpLayout {
+		t.Fatal("php layout not registered by name")
+	}
+
+	RegisterLayout(&Layout{Name: "test-same-magic", MagicNumber: MagicNumber, Length: HeaderLength})
+
+	if DetectLayout(MagicNumber) != DefaultLayout {
+		t.Fatal("layout with the built-in magic number took over detection")
+	}
+
+	if _, ok := LookupLayout("test-same-magic"); !ok {
+		t.Fatal("layout not registered by name")
+	}
+}
@@ -0,0 +1,26 @@
+package yar
+
+import "reflect"
+
+// Metadata 随请求/返回在调用链上传递的键值对，如 trace id、租户、语言等
+// 编码在数据包的 "x" 字段中，未实现该字段的 php-yar 会直接忽略
+type Metadata map[string]string
+
+// MetadataType 处理方法的第一个参数为该类型时，服务端会将请求的 Metadata 传入且不占用 rpc 参数
+// 服务端会把该 Metadata 带回给调用方，处理方法对其的修改同样会返回
+var MetadataType = reflect.TypeOf(Metadata{})
+
+func (m Metadata) Get(key string) string {
+	return m[key]
+}
+
+func (m Metadata) Copy() Metadata {
+
+	c := make(Metadata, len(m))
+
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metrics 指标上报接口，各子模块通过该接口输出计数、瞬时值以及耗时
+// labels 可能为 nil，实现方不应修改 labels
+type Metrics interface {
+	Counter(name string, value int64, labels map[string]string)
+	Gauge(name string, value float64, labels map[string]string)
+	Timing(name string, d time.Duration, labels map[string]string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Counter(name string, value int64, labels map[string]string) {}
+
+func (nopMetrics) Gauge(name string, value float64, labels map[string]string) {}
+
+func (nopMetrics) Timing(name string, d time.Duration, labels map[string]string) {}
+
+type holder struct {
+	m Metrics
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{m: nopMetrics{}})
+}
+
+// SetMetrics 设置全局的指标上报实现，传入 nil 则关闭上报
+func SetMetrics(m Metrics) {
+
+	if m == nil {
+		m = nopMetrics{}
+	}
+
+	current.Store(holder{m: m})
+}
+
+func GetMetrics() Metrics {
+	return current.Load().(holder).m
+}
+
+func Counter(name string, value int64, labels map[string]string) {
+	GetMetrics().Counter(name, value, labels)
+}
+
+func Gauge(name string, value float64, labels map[string]string) {
+	GetMetrics().Gauge(name, value, labels)
+}
+
+func Timing(name string, d time.Duration, labels map[string]string) {
+	GetMetrics().Timing(name, d, labels)
+}
+
+// Since 记录从 start 开始的耗时
+func Since(name string, start time.Time, labels map[string]string) {
+	GetMetrics().Timing(name, time.Since(start), labels)
+}
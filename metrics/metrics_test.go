@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics"
+	"github.com/weixinhost/yar.go/metrics/metricstest"
+)
+
+func TestSetMetrics(t *testing.T) {
+
+	recorder, restore := metricstest.Install()
+
+	labels := map[string]string{"k": "v"}
+	metrics.Counter("c", 3, labels)
+	metrics.Gauge("g", 1.5, nil)
+	metrics.Timing("t", time.Second, labels)
+	metrics.Since("s", time.Now().Add(-time.Minute), nil)
+
+	if records := recorder.Records("c", labels); len(records) != 1 || records[0].Kind != "counter" || records[0].Value != 3 {
+		t.Fatal(records)
+	}
+
+	if records := recorder.Records("g", nil); len(records) != 1 || records[0].Kind != "gauge" || records[0].Value != 1.5 {
+		t.Fatal(records)
+	}
+
+	if recorder.Sum("t", labels) != float64(time.Second) || recorder.Sum("s", nil) < float64(time.Minute) {
+		t.Fatal(recorder.Records("t", nil), recorder.Records("s", nil))
+	}
+
+	if len(recorder.Records("c", map[string]string{"k": "other"})) != 0 {
+		t.Fatal("labels not matched")
+	}
+
+	//传入 nil 后不再上报
+	restore()
+	metrics.Counter("c", 1, labels)
+
+	if len(recorder.Records("c", nil)) != 1 {
+		t.Fatal("metrics reported after SetMetrics(nil)")
+	}
+}
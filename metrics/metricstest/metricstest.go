@@ -0,0 +1,105 @@
+// Package metricstest 提供记录所有上报指标的 metrics.Metrics 实现，用于在测试中断言指标的名称、值与标签
+package metricstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+// Record 一次上报，Timing 的 Value 为纳秒数
+type Record struct {
+	Kind   string
+	Name   string
+	Value  float64
+	Labels map[string]string
+}
+
+// Recorder 按上报的顺序记录指标，可以并发使用
+type Recorder struct {
+	lock    sync.Mutex
+	records []Record
+}
+
+// Install 创建 Recorder 并设置为全局的指标上报实现，返回的函数恢复为不上报
+func Install() (*Recorder, func()) {
+	recorder := new(Recorder)
+	metrics.SetMetrics(recorder)
+	return recorder, func() {
+		metrics.SetMetrics(nil)
+	}
+}
+
+func (recorder *Recorder) Counter(name string, value int64, labels map[string]string) {
+	recorder.add("counter", name, float64(value), labels)
+}
+
+func (recorder *Recorder) Gauge(name string, value float64, labels map[string]string) {
+	recorder.add("gauge", name, value, labels)
+}
+
+func (recorder *Recorder) Timing(name string, d time.Duration, labels map[string]string) {
+	recorder.add("timing", name, float64(d), labels)
+}
+
+func (recorder *Recorder) add(kind string, name string, value float64, labels map[string]string) {
+
+	//复制标签，避免上报方之后修改
+	copied := make(map[string]string, len(labels))
+
+	for k, v := range labels {
+		copied[k] = v
+	}
+
+	recorder.lock.Lock()
+	recorder.records = append(recorder.records, Record{Kind: kind, Name: name, Value: value, Labels: copied})
+	recorder.lock.Unlock()
+}
+
+// Records 返回名称为 name 且包含 labels 中所有标签的记录
+func (recorder *Recorder) Records(name string, labels map[string]string) []Record {
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	var found []Record
+
+	for _, record := range recorder.records {
+		if record.Name == name && matchLabels(record.Labels, labels) {
+			found = append(found, record)
+		}
+	}
+
+	return found
+}
+
+// Sum 名称为 name 且包含 labels 中所有标签的记录的值之和
+func (recorder *Recorder) Sum(name string, labels map[string]string) float64 {
+
+	sum := 0.0
+
+	for _, record := range recorder.Records(name, labels) {
+		sum += record.Value
+	}
+
+	return sum
+}
+
+// Reset 清空已经记录的指标
+func (recorder *Recorder) Reset() {
+	recorder.lock.Lock()
+	recorder.records = nil
+	recorder.lock.Unlock()
+}
+
+func matchLabels(labels map[string]string, want map[string]string) bool {
+
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,71 @@
+package yar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (m Magic) String() string {
+
+	if m == MagicNumber {
+		return "YAR_PROTOCOL_MAGIC_NUM"
+	}
+
+	return fmt.Sprintf("0x%08X", uint32(m))
+}
+
+// ParseMagic 解析 String 的输出或十六进制的数值，如 0x80DFEC60
+func ParseMagic(s string) (Magic, error) {
+
+	if s == MagicNumber.String() {
+		return MagicNumber, nil
+	}
+
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return Magic(n), nil
+}
+
+// 与 php-yar 中的常量名保持一致，扩展状态沿用同样的前缀
+var errorTypeNames = map[ErrorType]string{
+	ERR_OKEY:           "YAR_ERR_OKEY",
+	ERR_PACKAGER:       "YAR_ERR_PACKAGER",
+	ERR_PROTOCOL:       "YAR_ERR_PROTOCOL",
+	ERR_REQUEST:        "YAR_ERR_REQUEST",
+	ERR_OUTPUT:         "YAR_ERR_OUTPUT",
+	ERR_TRANSPORT:      "YAR_ERR_TRANSPORT",
+	ERR_FORBIDDEN:      "YAR_ERR_FORBIDDEN",
+	ERR_EXCEPTION:      "YAR_ERR_EXCEPTION",
+	ERR_EMPTY_RESPONSE: "YAR_ERR_EMPTY_RESPONSE",
+	ERR_AUTH:           "YAR_ERR_AUTH",
+	ERR_THROTTLED:      "YAR_ERR_THROTTLED",
+	ERR_NOT_FOUND:      "YAR_ERR_NOT_FOUND",
+	ERR_INVALID_PARAMS: "YAR_ERR_INVALID_PARAMS",
+	ERR_INTERNAL:       "YAR_ERR_INTERNAL",
+}
+
+func (t ErrorType) String() string {
+
+	if name, ok := errorTypeNames[t]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("YAR_ERR_0x%X", int(t))
+}
+
+// ParseErrorType 解析 String 的输出，如 YAR_ERR_PACKAGER
+func ParseErrorType(s string) (ErrorType, bool) {
+
+	for t, name := range errorTypeNames {
+		if name == s {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
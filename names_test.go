@@ -0,0 +1,24 @@
+package yar
+
+import "testing"
+
+func TestNames(t *testing.T) {
+
+	if s := ERR_PACKAGER.String(); s != "YAR_ERR_PACKAGER" {
+		t.Fatal(s)
+	}
+
+	if st, ok := ParseErrorType("YAR_ERR_NOT_FOUND"); !ok || st != ERR_NOT_FOUND {
+		t.Fatal("parse error type", st)
+	}
+
+	for _, s := range []string{"YAR_PROTOCOL_MAGIC_NUM", "0x80DFEC60", "80dfec60"} {
+		if m, err := ParseMagic(s); err != nil || m != MagicNumber {
+			t.Fatal("parse magic", s, m, err)
+		}
+	}
+
+	if s := Magic(1).String(); s != "0x00000001" {
+		t.Fatal(s)
+	}
+}
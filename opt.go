@@ -24,27 +24,79 @@ const (
 )
 
 type Opt struct {
-	MagicNumber       uint32
+	MagicNumber       Magic
+	Version           uint16
 	Timeout           uint32
 	ConnectTimeout    uint32
 	Packager          string
+	Layout            string
+	Provider          string
+	Token             string
+	SignSecret        string
 	Encrypt           bool
 	EncryptPrivateKey string
+	KeyRing           *KeyRing
+	KeyId             uint8
 	DynamicParam      bool
 	DNSCache          bool
+	Canonical         bool
+	ChunkSize         int
+	MaxFrameSize      int
+	Compression       string
+	Checksum          bool
+	Persistent        bool
+	Multiplex         bool
+	Trailer           bool
+	ReplayProtection  bool
+	ReplayGuard       *ReplayGuard
 	LogLevel          int
 }
 
 func NewOpt() *Opt {
 	opt := new(Opt)
 	opt.MagicNumber = MagicNumber
+	//Version 支持的最高协议版本，设置为 ProtocolVersionLegacy 则不使用任何协议扩展
+	opt.Version = ProtocolVersion
 	opt.Encrypt = false
 	opt.EncryptPrivateKey = ""
+	//开启 Encrypt 后以 AES-GCM 加密数据，使用 KeyRing 中编号为 KeyId 的密钥，
+	//未设置 KeyRing 时使用 EncryptPrivateKey。分块与续帧模式下不进行加密
+	opt.KeyRing = nil
+	opt.KeyId = 0
 	opt.Packager = "json"
+	//Layout 客户端发送请求使用的头部布局，为空表示 DefaultLayout
+	//返回的布局按 MagicNumber 自动识别，内置布局的 MagicNumber 相同，都按 DefaultLayout 读取
+	opt.Layout = ""
+	//Provider 与 Token 写入每个请求的头部，也可以在单个请求上覆盖
+	opt.Provider = ""
+	opt.Token = ""
+	//SignSecret 不为空时，客户端以 HMAC 签名请求，签名写入 Token 并覆盖原有的 Token，不再使用分块与续帧模式
+	//服务端则拒绝未签名或签名错误的请求，并对返回签名
+	opt.SignSecret = ""
 	opt.ConnectTimeout = 1000 * 5
 	opt.Timeout = 30 * 1000
 	opt.DynamicParam = false
 	opt.DNSCache = true
+	opt.Canonical = false
+	//ChunkSize 大于 0 时以分块模式发送请求体，每块的最大字节数
+	opt.ChunkSize = 0
+	//Compression 请求体的压缩算法，如 snappy，为空表示不压缩。分块模式下不进行压缩
+	opt.Compression = ""
+	//MaxFrameSize 大于 0 时以续帧模式发送数据，每帧数据的最大字节数，需要对端支持协议版本 2
+	//优先级低于 ChunkSize，续帧模式下同样不进行压缩与校验
+	opt.MaxFrameSize = 0
+	//Checksum 对请求体计算 CRC32 校验值，服务端会以同样的方式返回
+	opt.Checksum = false
+	//Persistent 客户端:请求保持 tcp/unix 连接以复用；服务端:允许客户端保持连接
+	opt.Persistent = false
+	//Multiplex 客户端:在一条 tcp/unix 连接上并发发送请求；服务端:允许并发处理同一连接上的请求，需同时开启 Persistent
+	opt.Multiplex = false
+	//Trailer 客户端:请求服务端返回耗时信息，见 Response.Trailer；服务端:允许返回耗时信息及主机名
+	opt.Trailer = false
+	//ReplayProtection 客户端在每次发送时为请求写入时间戳与随机数
+	opt.ReplayProtection = false
+	//ReplayGuard 服务端不为空时校验请求的时间戳与随机数，拒绝过期或重复的请求
+	opt.ReplayGuard = nil
 	opt.LogLevel = LogLevelError
 	return opt
 }
@@ -0,0 +1,153 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// JsonCanonicalPack 以规范形式打包，保证相同的数据总是得到相同的字节序列，便于签名校验
+// 规则：对象(包括结构体)的键按字典序排列；整数按十进制输出；
+// 其他数字按 strconv 'g' 格式的最短形式输出；字符串不做 HTML 转义
+func JsonCanonicalPack(v interface{}) ([]byte, error) {
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+
+	if err = JsonUnpack(data, &generic); err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+
+	if err = writeCanonical(buffer, generic); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeCanonical(buffer *bytes.Buffer, v interface{}) error {
+
+	switch value := v.(type) {
+
+	case nil:
+		buffer.WriteString("null")
+
+	case bool:
+		buffer.WriteString(strconv.FormatBool(value))
+
+	case json.Number:
+		n, err := canonicalNumber(value)
+		if err != nil {
+			return err
+		}
+		buffer.WriteString(n)
+
+	case string:
+		return writeCanonicalString(buffer, value)
+
+	case []interface{}:
+		buffer.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeCanonical(buffer, item); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buffer.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := writeCanonicalString(buffer, k); err != nil {
+				return err
+			}
+			buffer.WriteByte(':')
+			if err := writeCanonical(buffer, value[k]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+
+	default:
+		return errors.New("canonical pack unsupported value")
+	}
+
+	return nil
+}
+
+func writeCanonicalString(buffer *bytes.Buffer, s string) error {
+
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(s); err != nil {
+		return err
+	}
+
+	//Encode 会在末尾追加换行
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+func canonicalNumber(n json.Number) (string, error) {
+
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+
+	if isIntegerLiteral(n.String()) {
+		return n.String(), nil
+	}
+
+	f, err := n.Float64()
+
+	if err != nil {
+		return "", err
+	}
+
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+func isIntegerLiteral(s string) bool {
+
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+
+	if len(s) < 1 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
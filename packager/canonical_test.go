@@ -0,0 +1,42 @@
+package packager
+
+import "testing"
+
+func TestJsonCanonicalPack(t *testing.T) {
+
+	type item struct {
+		Zeta  int    `json:"zeta"`
+		Alpha string `json:"alpha"`
+	}
+
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{map[string]interface{}{"b": 1, "a": 2, "c": map[string]interface{}{"y": 1, "x": 2}}, `{"a":2,"b":1,"c":{"x":2,"y":1}}`},
+		{item{Zeta: 1, Alpha: "a"}, `{"alpha":"a","zeta":1}`},
+		{[]interface{}{3, 1, 2}, `[3,1,2]`},
+		{1.0, `1`},
+		{-0.5, `-0.5`},
+		{1e21, `1e+21`},
+		{1.5e-7, `1.5e-07`},
+		{int64(9007199254740993), `9007199254740993`},
+		{uint64(18446744073709551615), `18446744073709551615`},
+		{"<a&b>", `"<a&b>"`},
+		{nil, `null`},
+		{true, `true`},
+	}
+
+	for _, test := range tests {
+
+		data, err := JsonCanonicalPack(test.value)
+
+		if err != nil {
+			t.Fatal(test.value, err)
+		}
+
+		if string(data) != test.expected {
+			t.Fatalf("%v: got %s, expected %s", test.value, data, test.expected)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package packager
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 分块模式下数据由若干个分块组成，每个分块为 4 字节大端长度 + 数据，长度为 0 的分块表示结束
+const (
+	DefaultChunkSize = 32 * 1024
+	MaxChunkSize     = 16 * 1024 * 1024
+)
+
+type ChunkWriter struct {
+	w      io.Writer
+	buffer []byte
+	closed bool
+}
+
+func NewChunkWriter(w io.Writer, size int) *ChunkWriter {
+
+	if size <= 0 || size > MaxChunkSize {
+		size = DefaultChunkSize
+	}
+
+	cw := new(ChunkWriter)
+	cw.w = w
+	cw.buffer = make([]byte, 0, size)
+	return cw
+}
+
+func (cw *ChunkWriter) Write(data []byte) (n int, err error) {
+
+	if cw.closed {
+		return 0, errors.New("write on closed chunk writer")
+	}
+
+	for len(data) > 0 {
+
+		free := cap(cw.buffer) - len(cw.buffer)
+
+		if free > len(data) {
+			free = len(data)
+		}
+
+		cw.buffer = append(cw.buffer, data[:free]...)
+		data = data[free:]
+		n += free
+
+		if len(cw.buffer) == cap(cw.buffer) {
+			if err = cw.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Flush 将已缓存的数据作为一个分块写出
+func (cw *ChunkWriter) Flush() error {
+
+	if len(cw.buffer) < 1 {
+		return nil
+	}
+
+	err := cw.writeChunk(cw.buffer)
+	cw.buffer = cw.buffer[0:0]
+	return err
+}
+
+// Close 写出剩余数据及结束分块，不会关闭下层的 Writer
+func (cw *ChunkWriter) Close() error {
+
+	if cw.closed {
+		return nil
+	}
+
+	if err := cw.Flush(); err != nil {
+		return err
+	}
+
+	cw.closed = true
+	return cw.writeChunk(nil)
+}
+
+func (cw *ChunkWriter) writeChunk(data []byte) error {
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+
+	if _, err := cw.w.Write(length[:]); err != nil {
+		return err
+	}
+
+	if len(data) < 1 {
+		return nil
+	}
+
+	_, err := cw.w.Write(data)
+	return err
+}
+
+type ChunkReader struct {
+	r      io.Reader
+	remain uint32
+	eof    bool
+}
+
+func NewChunkReader(r io.Reader) *ChunkReader {
+	cr := new(ChunkReader)
+	cr.r = r
+	return cr
+}
+
+func (cr *ChunkReader) Read(buffer []byte) (n int, err error) {
+
+	if cr.eof {
+		return 0, io.EOF
+	}
+
+	if cr.remain == 0 {
+
+		var length [4]byte
+
+		if _, err = io.ReadFull(cr.r, length[:]); err != nil {
+			return 0, errors.New("read chunk length error:" + err.Error())
+		}
+
+		cr.remain = binary.BigEndian.Uint32(length[:])
+
+		if cr.remain == 0 {
+			cr.eof = true
+			return 0, io.EOF
+		}
+
+		if cr.remain > MaxChunkSize {
+			return 0, errors.New("chunk too large")
+		}
+	}
+
+	if uint32(len(buffer)) > cr.remain {
+		buffer = buffer[:cr.remain]
+	}
+
+	n, err = cr.r.Read(buffer)
+	cr.remain -= uint32(n)
+
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
@@ -2,6 +2,7 @@ package packager
 
 import (
 	"encoding/json"
+	"io"
 	"strings"
 )
 
@@ -16,3 +17,13 @@ func JsonUnpack(data []byte, v interface{}) error {
 	err := d.Decode(v)
 	return err
 }
+
+func JsonPackTo(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func JsonUnpackFrom(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,50 @@
+package packager
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+// 上报的指标：
+// yar.packager.<op>.bytes     打包后/解包前的字节数
+// yar.packager.<op>.duration  耗时
+// yar.packager.<op>.errors    失败次数
+// op 为 pack 或 unpack，标签 packager 为打包协议名
+func observe(op string, name string, start time.Time, size int, err error) {
+
+	labels := map[string]string{"packager": strings.TrimRight(name, "\x00")}
+	prefix := "yar.packager." + op
+
+	if err != nil {
+		metrics.Counter(prefix+".errors", 1, labels)
+		return
+	}
+
+	metrics.Counter(prefix+".bytes", int64(size), labels)
+	metrics.Since(prefix+".duration", start, labels)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countWriter) Write(data []byte) (int, error) {
+	n, err := cw.w.Write(data)
+	cw.n += n
+	return n, err
+}
+
+type countReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countReader) Read(buffer []byte) (int, error) {
+	n, err := cr.r.Read(buffer)
+	cr.n += n
+	return n, err
+}
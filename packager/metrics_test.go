@@ -0,0 +1,58 @@
+package packager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/weixinhost/yar.go/metrics/metricstest"
+)
+
+func TestPackagerMetrics(t *testing.T) {
+
+	recorder, restore := metricstest.Install()
+	defer restore()
+
+	name := []byte("JSON\x00\x00\x00\x00")
+	labels := map[string]string{"packager": "json"}
+
+	data, err := Pack(name, map[string]int{"a": 1})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := recorder.Sum("yar.packager.pack.bytes", labels); sum != float64(len(data)) {
+		t.Fatal("pack bytes", sum, len(data))
+	}
+
+	if len(recorder.Records("yar.packager.pack.duration", labels)) != 1 {
+		t.Fatal("pack duration not reported")
+	}
+
+	var v map[string]int
+
+	if err = UnpackFrom(name, bytes.NewReader(data), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum := recorder.Sum("yar.packager.unpack.bytes", labels); sum != float64(len(data)) {
+		t.Fatal("unpack bytes", sum, len(data))
+	}
+
+	if err = Unpack(name, []byte("{"), &v); err == nil {
+		t.Fatal("invalid json accepted")
+	}
+
+	if sum := recorder.Sum("yar.packager.unpack.errors", labels); sum != 1 {
+		t.Fatal("unpack errors", sum)
+	}
+
+	//不支持的打包协议按名称上报失败
+	if _, err = Pack([]byte("PHP"), 1); err == nil {
+		t.Fatal("unsupported packager accepted")
+	}
+
+	if sum := recorder.Sum("yar.packager.pack.errors", map[string]string{"packager": "php"}); sum != 1 {
+		t.Fatal("pack errors", sum)
+	}
+}
@@ -0,0 +1,33 @@
+package packager
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Name 打包协议名，头部中以 8 字节保存，php-yar 使用大写的 JSON、MSGPACK、PHP
+type Name string
+
+const (
+	JSON    Name = "json"
+	Msgpack Name = "msgpack"
+	PHP     Name = "php"
+)
+
+func (n Name) String() string {
+	return string(n)
+}
+
+// ParseName 解析头部中的打包协议名，忽略大小写及末尾的 0 填充
+func ParseName(name []byte) (Name, bool) {
+
+	s := strings.ToLower(string(bytes.TrimRight(name, "\x00")))
+
+	for _, n := range []Name{JSON, Msgpack, PHP} {
+		if strings.Contains(s, string(n)) {
+			return n, true
+		}
+	}
+
+	return Name(s), false
+}
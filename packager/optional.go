@@ -0,0 +1,56 @@
+package packager
+
+import "bytes"
+
+// Optional 用于区分显式的 null 与缺失的字段
+// 作为结构体字段解包时：字段缺失则 Present 为 false；值为 null 则 Present 与 Null 均为 true
+type Optional struct {
+	Present bool
+	Null    bool
+	raw     []byte
+}
+
+func (o *Optional) UnmarshalJSON(data []byte) error {
+
+	o.Present = true
+	o.Null = bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+	o.raw = append(o.raw[0:0], data...)
+	return nil
+}
+
+// MarshalJSON 缺失或 null 时输出 null，其余原样输出
+func (o Optional) MarshalJSON() ([]byte, error) {
+
+	if !o.Present || o.Null || len(o.raw) < 1 {
+		return []byte("null"), nil
+	}
+
+	return o.raw, nil
+}
+
+// Valid 字段存在且不为 null
+func (o *Optional) Valid() bool {
+	return o.Present && !o.Null
+}
+
+// Decode 将字段的值解包到 v 中，字段缺失或为 null 时不修改 v
+func (o *Optional) Decode(v interface{}) error {
+
+	if !o.Valid() {
+		return nil
+	}
+
+	return JsonUnpack(o.raw, v)
+}
+
+// Value 以通用类型返回字段的值，字段缺失或为 null 时返回 nil
+func (o *Optional) Value() (interface{}, error) {
+
+	var v interface{}
+
+	if err := o.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
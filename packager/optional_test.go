@@ -0,0 +1,67 @@
+package packager
+
+import "testing"
+
+func TestOptional(t *testing.T) {
+
+	type payload struct {
+		Name Optional `json:"name"`
+	}
+
+	tests := []struct {
+		data    string
+		present bool
+		null    bool
+		value   interface{}
+		packed  string
+	}{
+		{`{}`, false, false, nil, `{"name":null}`},
+		{`{"name":null}`, true, true, nil, `{"name":null}`},
+		{`{"name":""}`, true, false, "", `{"name":""}`},
+		{`{"name":0}`, true, false, "0", `{"name":0}`},
+		{`{"name":false}`, true, false, false, `{"name":false}`},
+		{`{"name":"yar"}`, true, false, "yar", `{"name":"yar"}`},
+	}
+
+	for _, test := range tests {
+
+		var p payload
+
+		if err := JsonUnpack([]byte(test.data), &p); err != nil {
+			t.Fatal(test.data, err)
+		}
+
+		if p.Name.Present != test.present || p.Name.Null != test.null || p.Name.Valid() != (test.present && !test.null) {
+			t.Fatal(test.data, p.Name.Present, p.Name.Null)
+		}
+
+		value, err := p.Name.Value()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		//数字按 json.Number 解包
+		if n, ok := value.(interface{ String() string }); ok {
+			value = n.String()
+		}
+
+		if value != test.value {
+			t.Fatalf("%s: value %#v", test.data, value)
+		}
+
+		data, _ := JsonPack(p)
+
+		if string(data) != test.packed {
+			t.Fatal(test.data, string(data))
+		}
+	}
+
+	//缺失或 null 时 Decode 不修改原有的值
+	var missing payload
+	s := "default"
+
+	if err := missing.Name.Decode(&s); err != nil || s != "default" {
+		t.Fatal(s, err)
+	}
+}
@@ -0,0 +1,169 @@
+package packager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// OrderedMap 保留键顺序的 map，用于解包 PHP 关联数组等键顺序有意义的数据
+// 嵌套的对象同样解包为 *OrderedMap，数组为 []interface{}，数字为 json.Number
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func NewOrderedMap() *OrderedMap {
+	m := new(OrderedMap)
+	m.values = make(map[string]interface{})
+	return m
+}
+
+// Keys 按原始顺序返回全部键
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set 设置键值，新键追加在末尾，已存在的键保持原有位置
+func (m *OrderedMap) Set(key string, value interface{}) {
+
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = value
+}
+
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteByte('{')
+
+	for i, k := range m.keys {
+
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+func (m *OrderedMap) UnmarshalJSON(data []byte) error {
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	t, err := d.Token()
+
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return errors.New("ordered map unpack error: not an object")
+	}
+
+	m.keys = nil
+	m.values = make(map[string]interface{})
+	return decodeOrderedObject(d, m)
+}
+
+func decodeOrderedObject(d *json.Decoder, m *OrderedMap) error {
+
+	for d.More() {
+
+		t, err := d.Token()
+
+		if err != nil {
+			return err
+		}
+
+		key, ok := t.(string)
+
+		if !ok {
+			return errors.New("ordered map unpack error: invalid key")
+		}
+
+		value, err := decodeOrderedValue(d)
+
+		if err != nil {
+			return err
+		}
+
+		m.Set(key, value)
+	}
+
+	//读取结束的 '}'
+	_, err := d.Token()
+	return err
+}
+
+func decodeOrderedValue(d *json.Decoder) (interface{}, error) {
+
+	t, err := d.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := t.(json.Delim)
+
+	if !ok {
+		return t, nil
+	}
+
+	switch delim {
+
+	case '{':
+		m := NewOrderedMap()
+		if err := decodeOrderedObject(d, m); err != nil {
+			return nil, err
+		}
+		return m, nil
+
+	case '[':
+		list := []interface{}{}
+		for d.More() {
+			v, err := decodeOrderedValue(d)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+
+	return nil, errors.New("ordered map unpack error: unexpected delimiter")
+}
@@ -0,0 +1,71 @@
+package packager
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMapRoundTrip(t *testing.T) {
+
+	tests := []struct {
+		data string
+		keys []string
+	}{
+		{`{}`, nil},
+		{`{"z":1,"a":2,"m":3}`, []string{"z", "a", "m"}},
+		{`{"10":"x","2":"y","1":"z"}`, []string{"10", "2", "1"}},
+		{`{"b":{"y":1,"x":2},"a":[{"q":1,"p":2}]}`, []string{"b", "a"}},
+		//重复的键保留第一次出现的位置，取最后的值
+		{`{"a":1,"b":2,"a":3}`, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+
+		m := NewOrderedMap()
+
+		if err := JsonUnpack([]byte(test.data), m); err != nil {
+			t.Fatal(test.data, err)
+		}
+
+		if !reflect.DeepEqual(m.Keys(), test.keys) {
+			t.Fatal(test.data, m.Keys())
+		}
+
+		data, err := JsonPack(m)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		again := NewOrderedMap()
+
+		if err := JsonUnpack(data, again); err != nil || !reflect.DeepEqual(again.Keys(), m.Keys()) {
+			t.Fatal("order changed after round trip", string(data), err)
+		}
+	}
+
+	//嵌套的对象同样保留顺序，数字为 json.Number
+	m := NewOrderedMap()
+	JsonUnpack([]byte(`{"b":{"y":1,"x":2.5}}`), m)
+	nested, _ := m.Get("b")
+	inner := nested.(*OrderedMap)
+
+	if !reflect.DeepEqual(inner.Keys(), []string{"y", "x"}) {
+		t.Fatal(inner.Keys())
+	}
+
+	if x, _ := inner.Get("x"); x != json.Number("2.5") {
+		t.Fatal(x)
+	}
+
+	data, _ := JsonPack(inner)
+
+	if string(data) != `{"y":1,"x":2.5}` {
+		t.Fatal(string(data))
+	}
+
+	if err := JsonUnpack([]byte(`[1,2]`), NewOrderedMap()); err == nil {
+		t.Fatal("array accepted as ordered map")
+	}
+}
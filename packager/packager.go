@@ -2,7 +2,9 @@ package packager
 
 import (
 	"errors"
+	"io"
 	"strings"
+	"time"
 	//"bytes"
 	"bytes"
 )
@@ -13,26 +15,89 @@ type UnpackFunc func(data []byte, v interface{}) error
 
 func Pack(name []byte, v interface{}) ([]byte, error) {
 
+	start := time.Now()
 	s := strings.ToLower(bytes.NewBuffer(name).String())
 
-	if strings.Contains(s, "json") {
+	if n, _ := ParseName(name); n == JSON {
 
-		return JsonPack(v)
+		data, err := JsonPack(v)
+		observe("pack", s, start, len(data), err)
+		return data, err
 	}
 
-	return nil, errors.New("unsupported packager")
+	return nil, unsupported("pack", s)
+
+}
+
+// PackCanonical 以规范形式打包，目前只支持json
+func PackCanonical(name []byte, v interface{}) ([]byte, error) {
+
+	start := time.Now()
+	s := strings.ToLower(bytes.NewBuffer(name).String())
+
+	if n, _ := ParseName(name); n == JSON {
+
+		data, err := JsonCanonicalPack(v)
+		observe("pack", s, start, len(data), err)
+		return data, err
+	}
+
+	return nil, unsupported("pack", s)
 
 }
 
 func Unpack(name []byte, data []byte, v interface{}) error {
 
+	start := time.Now()
 	s := strings.ToLower(bytes.NewBuffer(name).String())
 
-	if strings.Contains(s, "json") {
+	if n, _ := ParseName(name); n == JSON {
 
-		return JsonUnpack(data, v)
+		err := JsonUnpack(data, v)
+		observe("unpack", s, start, len(data), err)
+		return err
 
 	}
 
-	return errors.New("unsupported packager")
+	return unsupported("unpack", s)
+}
+
+// PackTo 将 v 打包后直接写入 w，用于分块模式
+func PackTo(name []byte, w io.Writer, v interface{}) error {
+
+	start := time.Now()
+	s := strings.ToLower(bytes.NewBuffer(name).String())
+
+	if n, _ := ParseName(name); n == JSON {
+
+		cw := &countWriter{w: w}
+		err := JsonPackTo(cw, v)
+		observe("pack", s, start, cw.n, err)
+		return err
+	}
+
+	return unsupported("pack", s)
+}
+
+// UnpackFrom 从 r 中边读取边解包，用于分块模式
+func UnpackFrom(name []byte, r io.Reader, v interface{}) error {
+
+	start := time.Now()
+	s := strings.ToLower(bytes.NewBuffer(name).String())
+
+	if n, _ := ParseName(name); n == JSON {
+
+		cr := &countReader{r: r}
+		err := JsonUnpackFrom(cr, v)
+		observe("unpack", s, start, cr.n, err)
+		return err
+	}
+
+	return unsupported("unpack", s)
+}
+
+func unsupported(op string, name string) error {
+	err := errors.New("unsupported packager")
+	observe(op, name, time.Now(), 0, err)
+	return err
 }
@@ -0,0 +1,25 @@
+package packager
+
+import (
+	"errors"
+	"strings"
+)
+
+// ValidateFunc 校验数据是否满足约束，返回所有不满足约束的描述，为空表示校验通过
+type ValidateFunc func(v interface{}) []string
+
+// Validate 使用 fn 校验 v，存在违规项时返回列出全部违规项的错误
+func Validate(v interface{}, fn ValidateFunc) error {
+
+	if fn == nil {
+		return nil
+	}
+
+	violations := fn(v)
+
+	if len(violations) < 1 {
+		return nil
+	}
+
+	return errors.New("validate failed: " + strings.Join(violations, "; "))
+}
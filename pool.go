@@ -0,0 +1,76 @@
+package yar
+
+import "sync"
+
+// 每次调用及每个服务端请求都会创建头部、请求与返回对象，通过 sync.Pool 复用以减少分配
+// 对象在放回池中时重置，Release 之后不能再使用该对象及其 Protocol
+var (
+	headerPool   = sync.Pool{New: func() interface{} { return NewHeader() }}
+	requestPool  = sync.Pool{New: func() interface{} { return NewRequest() }}
+	responsePool = sync.Pool{New: func() interface{} { return NewResponse() }}
+)
+
+func AcquireHeader() *Header {
+	return headerPool.Get().(*Header)
+}
+
+func ReleaseHeader(header *Header) {
+	if header != nil {
+		header.Reset()
+		headerPool.Put(header)
+	}
+}
+
+// AcquireRequest 与 NewRequest 相同，返回带有新 Id 的请求
+func AcquireRequest() *Request {
+	request := requestPool.Get().(*Request)
+	request.Id = NextId()
+	return request
+}
+
+// ReleaseRequest 请求的 Protocol 随请求一起复用
+func ReleaseRequest(request *Request) {
+	if request != nil {
+		request.Reset()
+		requestPool.Put(request)
+	}
+}
+
+func AcquireResponse() *Response {
+	return responsePool.Get().(*Response)
+}
+
+// ReleaseResponse 返回的 Protocol 放回头部的池中
+func ReleaseResponse(response *Response) {
+
+	if response == nil {
+		return
+	}
+
+	ReleaseHeader(response.Protocol)
+	response.Reset()
+	responsePool.Put(response)
+}
+
+func (self *Header) Reset() {
+	*self = Header{}
+	self.MagicNumber = MagicNumber
+}
+
+func (self *Request) Reset() {
+
+	protocol := self.Protocol
+
+	if protocol == nil {
+		protocol = NewHeader()
+	} else {
+		protocol.Reset()
+	}
+
+	*self = Request{}
+	self.Protocol = protocol
+}
+
+func (self *Response) Reset() {
+	*self = Response{}
+}
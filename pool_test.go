@@ -0,0 +1,89 @@
+package yar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 设置请求、返回及头部的所有字段，复用时这些字段都不能带到下一次使用中
+func dirtyHeader() *Header {
+	header := NewHeader()
+	header.Id = 7
+	header.Version = ProtocolVersion
+	header.MagicNumber = 0x1234
+	header.SetFlag(FlagPersistent | FlagTrailer | FlagChunked)
+	header.SetProvider("provider")
+	header.SetToken("token")
+	header.Encrypt = 1
+	header.BodyLength = 100
+	header.SetPackager("json")
+	header.Layout = PhpLayout
+	return header
+}
+
+func TestReleaseRequestReset(t *testing.T) {
+
+	r := AcquireRequest()
+	protocol := dirtyHeader()
+	r.Protocol = protocol
+	r.Method = "Echo"
+	r.Params = []interface{}{1}
+	r.Metadata = Metadata{"trace": "t1"}
+	r.Body = []byte("body")
+	r.Deadline = time.Now()
+
+	ReleaseRequest(r)
+
+	//头部随请求一起复用
+	if r.Protocol != protocol || !reflect.DeepEqual(r, &Request{Protocol: NewHeader()}) {
+		t.Fatalf("request not reset: %+v %+v", r, r.Protocol)
+	}
+
+	reused := AcquireRequest()
+	defer ReleaseRequest(reused)
+
+	if reused.Id == 0 || reused.Method != "" || reused.Metadata != nil || reused.Body != nil || !reused.Deadline.IsZero() || !reflect.DeepEqual(reused.Protocol, NewHeader()) {
+		t.Fatalf("stale request fields: %+v %+v", reused, reused.Protocol)
+	}
+}
+
+func TestReleaseResponseReset(t *testing.T) {
+
+	response := AcquireResponse()
+	response.Protocol = dirtyHeader()
+	response.Id = 7
+	response.Error = "error"
+	response.Out = "out"
+	response.Status = ERR_EMPTY_RESPONSE
+	response.Retval = "ret"
+	response.Metadata = Metadata{"trace": "t1"}
+	response.Trailer = &Trailer{Hostname: "host"}
+	response.Body = []byte("body")
+	protocol := response.Protocol
+
+	ReleaseResponse(response)
+
+	if !reflect.DeepEqual(response, &Response{}) {
+		t.Fatalf("response not reset: %+v", response)
+	}
+
+	//返回的头部放回头部的池中，同样被重置
+	if !reflect.DeepEqual(protocol, NewHeader()) {
+		t.Fatalf("header not reset: %+v", protocol)
+	}
+
+	reused := AcquireResponse()
+	defer ReleaseResponse(reused)
+
+	if !reflect.DeepEqual(reused, &Response{}) {
+		t.Fatalf("stale response fields: %+v", reused)
+	}
+
+	header := AcquireHeader()
+	defer ReleaseHeader(header)
+
+	if !reflect.DeepEqual(header, NewHeader()) {
+		t.Fatalf("stale header fields: %+v", header)
+	}
+}
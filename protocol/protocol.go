@@ -0,0 +1,114 @@
+// Package protocol 提供构造、解析 yar 数据帧的公开接口，供代理、抓包工具及测试使用
+package protocol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/weixinhost/yar.go"
+)
+
+// Encode 输出 yar.HeaderLength 字节的头部
+func Encode(header *yar.Header) []byte {
+	return header.Encode()
+}
+
+// Decode 解析头部并进行长度校验，data 至少为 yar.HeaderLength 字节
+func Decode(data []byte) (*yar.Header, error) {
+	return yar.ParseHeader(data)
+}
+
+// EncodeFrame 输出头部与数据组成的完整帧，BodyLength 按 body 的长度设置
+func EncodeFrame(header *yar.Header, body []byte) []byte {
+	h := *header
+	h.BodyLength = uint32(yar.PackagerLength + len(body))
+	frame := make([]byte, 0, yar.HeaderLength+len(body))
+	frame = append(frame, h.Encode()...)
+	return append(frame, body...)
+}
+
+// DecodeFrame 解析完整帧，返回头部与打包协议名之后的数据
+func DecodeFrame(data []byte) (*yar.Header, []byte, error) {
+
+	header, err := yar.ParseHeader(data)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	end := yar.ProtocolLength + int(header.BodyLength)
+
+	if len(data) < end {
+		return nil, nil, fmt.Errorf("frame truncated: need %d bytes, got %d", end, len(data))
+	}
+
+	return header, data[yar.HeaderLength:end], nil
+}
+
+// Flags Reserved 字段中的标志位
+type Flags uint32
+
+func (f Flags) String() string {
+
+	var names []string
+
+	if uint32(f)&yar.FlagChunked != 0 {
+		names = append(names, "chunked")
+	}
+
+	if uint32(f)&yar.FlagChecksum != 0 {
+		names = append(names, "checksum")
+	}
+
+	if uint32(f)&yar.FlagPersistent != 0 {
+		names = append(names, "persistent")
+	}
+
+	if uint32(f)&yar.FlagContinuation != 0 {
+		names = append(names, "continuation")
+	}
+
+	if uint32(f)&yar.FlagTrailer != 0 {
+		names = append(names, "trailer")
+	}
+
+	if uint32(f)&yar.FlagSigned != 0 {
+		names = append(names, "signed")
+	}
+
+	if uint32(f)&yar.FlagMultiplex != 0 {
+		names = append(names, "multiplex")
+	}
+
+	if uint32(f)&yar.FlagPing != 0 {
+		names = append(names, "ping")
+	}
+
+	if id := (uint32(f) & yar.FlagCompressMask) >> yar.FlagCompressShift; id != 0 {
+		names = append(names, fmt.Sprintf("compress=%d", id))
+	}
+
+	if id := (uint32(f) & yar.FlagKeyIdMask) >> yar.FlagKeyIdShift; id != 0 {
+		names = append(names, fmt.Sprintf("key=%d", id))
+	}
+
+	known := yar.FlagChunked | yar.FlagChecksum | yar.FlagPersistent | yar.FlagContinuation | yar.FlagTrailer |
+		yar.FlagSigned | yar.FlagMultiplex | yar.FlagPing | yar.FlagCompressMask | yar.FlagKeyIdMask
+
+	if rest := uint32(f) &^ known; rest != 0 {
+		names = append(names, fmt.Sprintf("0x%x", rest))
+	}
+
+	if len(names) < 1 {
+		return "none"
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Describe 返回头部的可读描述
+func Describe(header *yar.Header) string {
+	return fmt.Sprintf("id=%d version=%d magic=%s flags=%s provider=%q encrypt=%d body=%d packager=%q",
+		header.Id, header.Version, header.MagicNumber, Flags(header.Reserved), header.ProviderString(),
+		header.Encrypt, header.BodyLength, header.PackagerString())
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/weixinhost/yar.go"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+
+	header := yar.NewHeader()
+	header.Id = 7
+	header.SetPackager("json")
+	header.SetFlag(yar.FlagChecksum)
+	header.SetCompression(1)
+
+	body := []byte(`{"i":7}`)
+	frame := EncodeFrame(header, body)
+
+	decoded, decodedBody, err := DecodeFrame(frame)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Id != 7 || decoded.PackagerString() != "json" || !bytes.Equal(decodedBody, body) {
+		t.Fatalf("unexpected frame: %s %q", Describe(decoded), decodedBody)
+	}
+
+	if s := Flags(decoded.Reserved).String(); s != "checksum|compress=1" {
+		t.Fatalf("flags: %s", s)
+	}
+
+	if _, _, err = DecodeFrame(frame[:len(frame)-1]); err == nil {
+		t.Fatal("expected error for truncated frame")
+	}
+}
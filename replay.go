@@ -0,0 +1,87 @@
+package yar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 防重放所用的时间戳与随机数保存在请求的 Metadata 中，未开启校验的服务端及 php-yar 会忽略
+const (
+	MetaTimestamp = "yar-ts"
+	MetaNonce     = "yar-nonce"
+)
+
+var (
+	ErrReplayMissing = errors.New("request missing timestamp or nonce")
+	ErrReplayExpired = errors.New("request timestamp out of window")
+	ErrReplayNonce   = errors.New("request nonce already used")
+)
+
+// Stamp 为请求写入当前时间戳与随机数，每次发送前调用
+func (self *Request) Stamp() {
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	self.SetMeta(MetaTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	self.SetMeta(MetaNonce, hex.EncodeToString(nonce[:]))
+}
+
+// ReplayGuard 校验请求的时间戳是否在窗口内，并记录窗口内出现过的随机数
+// 同一服务的多个 Server 实例需要共享同一个 ReplayGuard
+type ReplayGuard struct {
+	Window time.Duration
+	lock   sync.Mutex
+	nonces map[string]int64
+	sweep  int64
+}
+
+func NewReplayGuard(window time.Duration) *ReplayGuard {
+	guard := new(ReplayGuard)
+	guard.Window = window
+	guard.nonces = make(map[string]int64)
+	return guard
+}
+
+func (self *ReplayGuard) Check(meta Metadata) error {
+	return self.check(meta, time.Now())
+}
+
+func (self *ReplayGuard) check(meta Metadata, now time.Time) error {
+
+	ts, err := strconv.ParseInt(meta.Get(MetaTimestamp), 10, 64)
+	nonce := meta.Get(MetaNonce)
+
+	if err != nil || len(nonce) < 1 {
+		return ErrReplayMissing
+	}
+
+	window := int64(self.Window / time.Second)
+	unix := now.Unix()
+
+	if ts < unix-window || ts > unix+window {
+		return ErrReplayExpired
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	//随机数在时间戳超出窗口后才会过期，过期的记录每个窗口清理一次
+	if unix-self.sweep > window {
+		for k, expire := range self.nonces {
+			if expire < unix {
+				delete(self.nonces, k)
+			}
+		}
+		self.sweep = unix
+	}
+
+	if _, ok := self.nonces[nonce]; ok {
+		return ErrReplayNonce
+	}
+
+	self.nonces[nonce] = ts + window
+	return nil
+}
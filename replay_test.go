@@ -0,0 +1,35 @@
+package yar
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+
+	guard := NewReplayGuard(time.Minute)
+	now := time.Now()
+
+	r := NewRequest()
+	r.Stamp()
+
+	if err := guard.check(r.Metadata, now); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := guard.check(r.Metadata, now); err != ErrReplayNonce {
+		t.Fatalf("replayed nonce: %v", err)
+	}
+
+	r.Stamp()
+	r.SetMeta(MetaTimestamp, strconv.FormatInt(now.Add(-2*time.Minute).Unix(), 10))
+
+	if err := guard.check(r.Metadata, now); err != ErrReplayExpired {
+		t.Fatalf("expired timestamp: %v", err)
+	}
+
+	if err := guard.check(Metadata{}, now); err != ErrReplayMissing {
+		t.Fatalf("missing fields: %v", err)
+	}
+}
@@ -1,17 +1,35 @@
 package yar
 
-import "math/rand"
+import "time"
 
 type Request struct {
 	Protocol *Header     `json:"-" msgpack:"-"`
 	Id       uint32      `json:"i" msgpack:"i"`
 	Method   string      `json:"m" msgpack:"m"`
 	Params   interface{} `json:"p" msgpack:"p"`
+	Metadata Metadata    `json:"x,omitempty" msgpack:"x,omitempty"`
+	//Body 打包及编码后待发送的数据，不含头部，由传输层的 Send 写出
+	Body []byte `json:"-" msgpack:"-"`
+	//Deadline 不为零时与 Opt.Timeout 取较早的时间作为本次调用的截止时间，不发送给服务端
+	Deadline time.Time `json:"-" msgpack:"-"`
 }
 
 func NewRequest() (request *Request) {
 	request = new(Request)
 	request.Protocol = NewHeader()
-	request.Id = rand.Uint32()
+	request.Id = NextId()
 	return request
 }
+
+func (self *Request) SetMeta(key string, value string) {
+
+	if self.Metadata == nil {
+		self.Metadata = make(Metadata)
+	}
+
+	self.Metadata[key] = value
+}
+
+func (self *Request) GetMeta(key string) string {
+	return self.Metadata.Get(key)
+}
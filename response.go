@@ -7,6 +7,11 @@ type Response struct {
 	Out      string      `json:"o" msgpack:"o"`
 	Status   ErrorType   `json:"s" msgpack:"s"`
 	Retval   interface{} `json:"r" msgpack:"r"`
+	Metadata Metadata    `json:"x,omitempty" msgpack:"x,omitempty"`
+	//Trailer 服务端返回的耗时信息，未返回时为空
+	Trailer *Trailer `json:"-" msgpack:"-"`
+	//Body 传输层 Recv 读取的原始数据，不含头部
+	Body []byte `json:"-" msgpack:"-"`
 }
 
 func NewResponse() (response *Response) {
@@ -30,3 +35,16 @@ func (self *Response) Return(v interface{}) (err error) {
 	self.Retval = v
 	return nil
 }
+
+func (self *Response) SetMeta(key string, value string) {
+
+	if self.Metadata == nil {
+		self.Metadata = make(Metadata)
+	}
+
+	self.Metadata[key] = value
+}
+
+func (self *Response) GetMeta(key string) string {
+	return self.Metadata.Get(key)
+}
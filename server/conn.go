@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+// 连接上每次读取请求、写出返回前刷新超时时间
+type timeoutConn interface {
+	SetReadTimeout(timeout time.Duration)
+	SetWriteTimeout(timeout time.Duration)
+}
+
+const connTimeout = transports.CONNECTION_READ_TIMEOUT_SECOND * time.Second
+
+// ServeConn 处理 tcp/unix 连接上的请求，可以配合 transports.Sock 的 OnConnection 使用
+// 请求带有 FlagPersistent 且 Opt.Persistent 开启时，处理完成后继续在该连接上读取下一个请求，否则关闭连接
+// 请求同时带有 FlagMultiplex 且 Opt.Multiplex 开启时，请求被并发处理，返回按完成的顺序写回
+// 带有 FlagPing 的探测帧直接回复 pong，同时刷新连接的超时时间
+// 同一个 Server 可以同时处理多个连接
+func (server *Server) ServeConn(conn io.ReadWriteCloser) *yar.Error {
+
+	defer conn.Close()
+
+	//续帧模式下从该连接读取后续帧，连接的状态记录在单独的 Server 上
+	handler := server.stream()
+	handler.reader = conn
+
+	timeouts, _ := conn.(timeoutConn)
+	streams := new(sync.WaitGroup)
+	writeLock := new(sync.Mutex)
+	defer streams.Wait()
+
+	for {
+
+		if timeouts != nil {
+			timeouts.SetReadTimeout(connTimeout)
+			timeouts.SetWriteTimeout(connTimeout)
+		}
+
+		frame, header, err := yar.ReadFrame(conn)
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			server.log(yar.LogLevelError, "[ServeConn] read frame error:%s", err.Error())
+			return yar.NewError(yar.ErrorNetwork, err.Error())
+		}
+
+		persistent := server.Opt.Persistent && header.HasFlag(yar.FlagPersistent)
+		multiplex := persistent && server.Opt.Multiplex && header.HasFlag(yar.FlagMultiplex) && !header.HasFlag(yar.FlagContinuation)
+
+		if header.HasFlag(yar.FlagPing) {
+			err = server.pong(header, persistent, conn, timeouts, writeLock)
+			yar.ReleaseHeader(header)
+			if err != nil || !persistent {
+				return nil
+			}
+			continue
+		}
+
+		yar.ReleaseHeader(header)
+
+		if multiplex {
+			streams.Add(1)
+			go server.serveStream(frame, conn, timeouts, writeLock, streams)
+			continue
+		}
+
+		//等待并发处理的请求写完，避免返回交错
+		streams.Wait()
+
+		if callErr := handler.Handle(frame, conn); callErr != nil && !callErr.Assert(yar.ErrorResponse) {
+			//头部或者数据错误时没有写回任何数据，无法继续使用该连接
+			return callErr
+		}
+
+		if !persistent {
+			return nil
+		}
+	}
+}
+
+// 并发处理一个请求，返回完整地写入缓冲后再写回连接
+func (server *Server) serveStream(frame []byte, conn io.ReadWriteCloser, timeouts timeoutConn, writeLock *sync.Mutex, streams *sync.WaitGroup) {
+
+	defer streams.Done()
+
+	stream := server.stream()
+	buffer := new(bytes.Buffer)
+
+	if callErr := stream.Handle(frame, buffer); callErr != nil && !callErr.Assert(yar.ErrorResponse) {
+		//没有返回可以写回，关闭连接使对端的请求失败而不是等待超时
+		conn.Close()
+		return
+	}
+
+	if timeouts != nil {
+		timeouts.SetWriteTimeout(connTimeout)
+	}
+
+	if err := writeFrame(conn, writeLock, buffer.Bytes()); err != nil {
+		server.log(yar.LogLevelError, "[ServeConn] write response error:%s", err.Error())
+		conn.Close()
+	}
+}
+
+// 回复探测帧，不同意保持连接时 pong 不带 FlagPersistent
+func (server *Server) pong(ping *yar.Header, persistent bool, conn io.Writer, timeouts timeoutConn, writeLock *sync.Mutex) error {
+
+	pong := yar.NewHeader()
+	pong.Id = ping.Id
+	pong.MagicNumber = ping.MagicNumber
+	pong.Layout = ping.Layout
+	pong.Version = ping.Version
+	pong.BodyLength = yar.PackagerLength
+	pong.SetFlag(yar.FlagPing)
+
+	if persistent {
+		pong.SetFlag(yar.FlagPersistent)
+	}
+
+	if timeouts != nil {
+		timeouts.SetWriteTimeout(connTimeout)
+	}
+
+	err := writeFrame(conn, writeLock, pong.WireBytes())
+
+	if err != nil {
+		server.log(yar.LogLevelError, "[ServeConn] write pong error:%s", err.Error())
+	}
+
+	return err
+}
+
+// 写出一个完整的帧，连接支持 WriteBuffers 时同时完成的返回合并写出，否则加锁依次写出
+func writeFrame(conn io.Writer, writeLock *sync.Mutex, frame []byte) error {
+
+	if _, ok := conn.(transports.BuffersWriter); !ok {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+	}
+
+	_, err := transports.WriteBuffers(conn, frame)
+	return err
+}
+
+// stream 返回共享方法表与配置的 Server，用于并发处理请求
+func (server *Server) stream() *Server {
+	stream := new(Server)
+	stream.class = server.class
+	stream.methodMap = server.methodMap
+	stream.Opt = server.Opt
+	return stream
+}
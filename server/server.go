@@ -5,20 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"os"
 	"reflect"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/weixinhost/yar.go"
 	"github.com/weixinhost/yar.go/packager"
+	"github.com/weixinhost/yar.go/transports"
 )
 
+var hostname, _ = os.Hostname()
+
 type Server struct {
 	class     interface{}
 	methodMap map[string]string
 	body      []byte
+	reader    io.Reader
 	Opt       *yar.Opt
 	writer    io.Writer
 }
@@ -37,6 +44,7 @@ func (server *Server) Register(rpcName string, methodName string) {
 }
 
 func (server *Server) Handle(body []byte, writer io.Writer) *yar.Error {
+	received := time.Now()
 	server.body = body
 	server.writer = writer
 
@@ -51,6 +59,13 @@ func (server *Server) Handle(body []byte, writer io.Writer) *yar.Error {
 		return err
 	}
 
+	version := yar.NegotiateVersion(header.Version, server.Opt.Version)
+
+	if yar.RequiredVersion(header.Reserved) > version {
+		server.log(yar.LogLevelError, "[YarCall] request flags %x unsupported by protocol version %d", header.Reserved, version)
+		return yar.NewError(yar.ErrorProtocol, "request uses protocol extensions unsupported by negotiated version")
+	}
+
 	request, err := server.readRequest(header)
 
 	if err != nil {
@@ -58,11 +73,53 @@ func (server *Server) Handle(body []byte, writer io.Writer) *yar.Error {
 		return err
 	}
 
-	response := yar.NewResponse()
+	defer yar.ReleaseRequest(request)
+
+	response := yar.AcquireResponse()
+	defer yar.ReleaseResponse(response)
 	response.Status = yar.ERR_OKEY
 	response.Protocol = header
+	response.Protocol.Version = version
+
+	if !server.Opt.Persistent {
+		response.Protocol.ClearFlag(yar.FlagPersistent)
+	}
+
+	if !server.Opt.Persistent || !server.Opt.Multiplex {
+		response.Protocol.ClearFlag(yar.FlagMultiplex)
+	}
+
+	if len(server.Opt.SignSecret) < 1 {
+		response.Protocol.ClearFlag(yar.FlagSigned)
+	}
+	response.Id = request.Id
+	//Metadata 原样带回给调用方
+	response.Metadata = request.Metadata
+
+	if server.Opt.ReplayGuard != nil {
+		if replayErr := server.Opt.ReplayGuard.Check(request.Metadata); replayErr != nil {
+			response.Status = yar.ERR_AUTH
+			response.Error = "replay check failed:" + replayErr.Error()
+		}
+	}
 
-	server.call(request, response)
+	trailer := server.Opt.Trailer && header.HasFlag(yar.FlagTrailer)
+	started := time.Now()
+
+	if response.Status == yar.ERR_OKEY {
+		server.call(request, response)
+	}
+
+	if trailer {
+		response.Trailer = new(yar.Trailer)
+		response.Trailer.QueueTime = started.Sub(received)
+		response.Trailer.HandlerTime = time.Since(started)
+		response.Trailer.Hostname = hostname
+	} else {
+		response.Protocol.ClearFlag(yar.FlagTrailer)
+	}
+	//扩展状态只发给协商版本支持的对端
+	response.Status = response.Status.ForVersion(response.Protocol.Version)
 	server.sendResponse(response)
 	if response.Status != yar.ERR_OKEY {
 		server.log(yar.LogLevelError, "[YarCall] %d %s Error:%s\n", request.Id, request.Method, response.Error)
@@ -75,27 +132,32 @@ func (server *Server) Handle(body []byte, writer io.Writer) *yar.Error {
 
 func (server *Server) readHeader() (*yar.Header, *yar.Error) {
 
-	headerBuffer := bytes.NewBuffer(server.body[0 : yar.ProtocolLength+yar.ProtocolLength])
+	header, parseErr := yar.ParseHeader(server.body)
 
-	header := yar.NewHeaderWithBytes(headerBuffer)
+	if parseErr != nil {
+		return nil, yar.NewError(yar.ErrorProtocol, "parse header error:"+parseErr.Error())
+	}
 
 	if header.MagicNumber != server.Opt.MagicNumber {
 		return nil, yar.NewError(yar.ErrorProtocol, "magic number check failed.")
 	}
 
 	encrypt := server.Opt.Encrypt
-	encryptKey := ""
 
 	if header.Encrypt == 1 {
 		if encrypt == false {
 			return nil, yar.NewError(yar.ErrorProtocol, "this is a encrypt request,but server not support encrypt mode.")
 		}
 
-		if len(encryptKey) < 1 {
+		if !server.hasKey(header.KeyId()) {
 			return nil, yar.NewError(yar.ErrorProtocol, "this is a encrypt request,but server not set a encrypt private key")
 		}
 	}
 
+	if len(server.Opt.SignSecret) > 0 && !header.HasFlag(yar.FlagSigned) {
+		return nil, yar.NewError(yar.ErrorVerify, "server requires signed request")
+	}
+
 	if header.Encrypt == 0 && encrypt == true {
 		return nil, yar.NewError(yar.ErrorProtocol, "this server is encrypt,but request is not encrypt mode")
 	}
@@ -103,14 +165,49 @@ func (server *Server) readHeader() (*yar.Header, *yar.Error) {
 	return header, nil
 }
 
-func (server *Server) readRequest(header *yar.Header) (*yar.Request, *yar.Error) {
-	server.log(yar.LogLevelDebug, "[readRequest] %d %s %d %d", header.Id, header.Packager, header.MagicNumber, header.BodyLength)
-	bodyLen := header.BodyLength
-	bodyBuffer := server.body[90 : 90+bodyLen-8]
+func (server *Server) hasKey(id uint8) bool {
 
-	request := yar.NewRequest()
+	if server.Opt.KeyRing != nil {
+		_, ok := server.Opt.KeyRing.Get(id)
+		return ok
+	}
+
+	return id == 0 && len(server.Opt.EncryptPrivateKey) > 0
+}
 
-	err := packager.Unpack(header.Packager[:], bodyBuffer, request)
+func (server *Server) readRequest(header *yar.Header) (*yar.Request, *yar.Error) {
+	server.log(yar.LogLevelDebug, "[readRequest] %d %s %s %d", header.Id, header.PackagerString(), header.MagicNumber, header.BodyLength)
+	request := yar.AcquireRequest()
+
+	var err error
+
+	if header.HasFlag(yar.FlagChunked) {
+		reader := packager.NewChunkReader(bytes.NewReader(server.body[yar.HeaderLength:]))
+		err = packager.UnpackFrom(header.Packager[:], reader, request)
+	} else if header.HasFlag(yar.FlagContinuation) {
+		//HTTP 下后续帧都在 body 中，tcp/unix 连接下后续帧还在连接中
+		var rest io.Reader = bytes.NewReader(server.body[yar.HeaderLength:])
+		if server.reader != nil {
+			rest = io.MultiReader(rest, server.reader)
+		}
+		reader := yar.NewFrameReader(rest, header)
+		err = packager.UnpackFrom(header.Packager[:], reader, request)
+		io.Copy(ioutil.Discard, reader)
+	} else {
+		//ParseHeader 已保证 BodyLength >= PackagerLength
+		end := uint64(yar.ProtocolLength) + uint64(header.BodyLength)
+		if uint64(len(server.body)) < end {
+			return nil, yar.NewError(yar.ErrorRequest, "request body shorter than body length")
+		}
+		if len(server.Opt.SignSecret) > 0 && !yar.VerifyHeader(header, server.Opt.SignSecret, server.body[yar.HeaderLength:end]) {
+			return nil, yar.NewError(yar.ErrorVerify, "request signature mismatch")
+		}
+		bodyBuffer, decodeErr := yar.DecodeBody(header, server.Opt, server.body[yar.HeaderLength:end])
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		err = packager.Unpack(header.Packager[:], bodyBuffer, request)
+	}
 
 	if err != nil {
 		return nil, yar.NewError(yar.ErrorPackager, err.Error())
@@ -120,23 +217,126 @@ func (server *Server) readRequest(header *yar.Header) (*yar.Request, *yar.Error)
 }
 
 func (server *Server) sendResponse(response *yar.Response) *yar.Error {
-	server.log(yar.LogLevelDebug, "[sendResponse] %d %d %s", response.Id, response.Status, fmt.Sprint(response.Retval))
-	sendPackData, err := packager.Pack(response.Protocol.Packager[:], response)
+	server.log(yar.LogLevelDebug, "[sendResponse] %d %s %s", response.Id, response.Status, fmt.Sprint(response.Retval))
+
+	//请求为分块模式时，返回同样使用分块模式
+	if response.Protocol.HasFlag(yar.FlagChunked) {
+		response.Protocol.ClearFlag(yar.FlagTrailer | yar.FlagContinuation)
+		return server.sendChunkedResponse(response)
+	}
+
+	//并发模式下返回只占一帧，以便按 Id 对应
+	if server.Opt.ChunkSize <= 0 && server.Opt.MaxFrameSize > 0 && response.Protocol.Version >= yar.ProtocolVersionContinuation && response.Protocol.Encrypt == 0 &&
+		!response.Protocol.HasFlag(yar.FlagMultiplex) {
+		return server.sendFramedResponse(response)
+	}
+
+	//头部复制自请求，续帧的请求按单帧返回时去掉续帧标志，否则调用方会继续等待后续帧
+	response.Protocol.ClearFlag(yar.FlagContinuation)
+
+	var sendPackData []byte
+	var err error
+
+	if server.Opt.Canonical {
+		sendPackData, err = packager.PackCanonical(response.Protocol.Packager[:], response)
+	} else {
+		sendPackData, err = packager.Pack(response.Protocol.Packager[:], response)
+	}
+
 	if err != nil {
 		return yar.NewError(yar.ErrorResponse, err.Error())
 	}
+
+	sendPackData, encodeErr := yar.EncodeBody(response.Protocol, server.Opt, sendPackData)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	//签名只对数据计算，Trailer 不参与签名
+	if len(server.Opt.SignSecret) > 0 && response.Protocol.HasFlag(yar.FlagSigned) {
+		yar.SignHeader(response.Protocol, server.Opt.SignSecret, sendPackData)
+	}
+
+	if response.Trailer != nil && response.Protocol.HasFlag(yar.FlagTrailer) {
+		sendPackData = append(sendPackData, response.Trailer.Encode()...)
+	}
+
 	response.Protocol.BodyLength = uint32(len(sendPackData) + 8)
-	server.writer.Write(response.Protocol.Bytes().Bytes())
-	server.writer.Write(sendPackData)
+
+	//头部与数据一次写出，避免头部单独成为一个小包
+	if _, err = transports.WriteBuffers(server.writer, response.Protocol.Bytes().Bytes(), sendPackData); err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
 	return nil
 
 }
 
+func (server *Server) sendFramedResponse(response *yar.Response) *yar.Error {
+
+	fw := yar.NewFrameWriter(server.writer, response.Protocol, server.Opt.MaxFrameSize)
+
+	var err error
+
+	if server.Opt.Canonical {
+		var sendPackData []byte
+		sendPackData, err = packager.PackCanonical(response.Protocol.Packager[:], response)
+		if err == nil {
+			_, err = fw.Write(sendPackData)
+		}
+	} else {
+		err = packager.PackTo(response.Protocol.Packager[:], fw, response)
+	}
+
+	if err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
+	if err = fw.Close(); err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
+	return nil
+}
+
+func (server *Server) sendChunkedResponse(response *yar.Response) *yar.Error {
+
+	response.Protocol.BodyLength = yar.PackagerLength
+
+	if _, err := server.writer.Write(response.Protocol.Bytes().Bytes()); err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
+	cw := packager.NewChunkWriter(server.writer, server.Opt.ChunkSize)
+
+	var err error
+
+	if server.Opt.Canonical {
+		var sendPackData []byte
+		sendPackData, err = packager.PackCanonical(response.Protocol.Packager[:], response)
+		if err == nil {
+			_, err = cw.Write(sendPackData)
+		}
+	} else {
+		err = packager.PackTo(response.Protocol.Packager[:], cw, response)
+	}
+
+	if err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
+	if err = cw.Close(); err != nil {
+		return yar.NewError(yar.ErrorResponse, err.Error())
+	}
+
+	return nil
+}
+
 func (server *Server) call(request *yar.Request, response *yar.Response) {
 
 	defer func() {
 		if r := recover(); r != nil {
-			response.Status = yar.ERR_EMPTY_RESPONSE
+			response.Status = yar.ERR_INTERNAL
 			response.Error = "call handler internal panic:" + fmt.Sprint(r)
 			if server.Opt.LogLevel&yar.LogLevelError > 0 {
 				fmt.Println(r)
@@ -161,33 +361,51 @@ func (server *Server) call(request *yar.Request, response *yar.Response) {
 	}
 
 	if err == false {
-		response.Status = yar.ERR_EMPTY_RESPONSE
+		response.Status = yar.ERR_NOT_FOUND
 		response.Error = "call undefined api:" + request.Method
 		return
 	}
 
 	fv := class_fv.MethodByName(methodMap)
 
+	//第一个参数为 yar.Metadata 时传入请求的 Metadata
+	offset := 0
+
+	if fv.Type().NumIn() > 0 && fv.Type().In(0) == yar.MetadataType {
+		offset = 1
+	}
+
 	var real_params []reflect.Value
 
 	if server.Opt.DynamicParam {
 		real_params = make([]reflect.Value, fv.Type().NumIn())
 	} else {
 
-		if len(call_params) != fv.Type().NumIn() {
-			response.Status = yar.ERR_EMPTY_RESPONSE
+		if len(call_params) != fv.Type().NumIn()-offset {
+			response.Status = yar.ERR_INVALID_PARAMS
 			response.Error = "mismatch handler param size"
 			return
 		}
 
-		real_params = make([]reflect.Value, len(call_params))
+		real_params = make([]reflect.Value, len(call_params)+offset)
+	}
+
+	if offset > 0 {
+
+		if request.Metadata == nil {
+			request.Metadata = make(yar.Metadata)
+			//请求没有带 Metadata 时同样返回处理方法写入的值
+			response.Metadata = request.Metadata
+		}
+
+		real_params[0] = reflect.ValueOf(request.Metadata)
 	}
 
 	func() {
 
-		for i := 0; i < len(real_params); i++ {
+		for i := offset; i < len(real_params); i++ {
 
-			if i >= len(call_params) {
+			if i-offset >= len(call_params) {
 				tv := fv.Type().In(i).Kind()
 				if tv == reflect.Ptr || tv == reflect.Map || tv == reflect.Array || reflect.Interface == tv {
 					real_params[i] = reflect.New(fv.Type().In(i))
@@ -202,7 +420,7 @@ func (server *Server) call(request *yar.Request, response *yar.Response) {
 				real_params[i] = reflect.New(fv.Type().In(i))
 			}
 
-			v := call_params[i]
+			v := call_params[i-offset]
 
 			raw_val := reflect.ValueOf(v)
 
@@ -317,7 +535,7 @@ func (server *Server) call(request *yar.Request, response *yar.Response) {
 				}
 
 				if coverErr != nil {
-					response.Status = yar.ERR_EMPTY_RESPONSE
+					response.Status = yar.ERR_INVALID_PARAMS
 					response.Error = "cover number type error:" + coverErr.Error()
 					return
 				}
@@ -450,7 +668,7 @@ func (server *Server) call(request *yar.Request, response *yar.Response) {
 				}
 
 				if coverErr != nil {
-					response.Status = yar.ERR_EMPTY_RESPONSE
+					response.Status = yar.ERR_INVALID_PARAMS
 					response.Error = "cover string to number error:" + coverErr.Error()
 					return
 				}
@@ -471,7 +689,7 @@ func (server *Server) call(request *yar.Request, response *yar.Response) {
 		}
 
 		if len(rs) > 1 {
-			response.Status = yar.ERR_EMPTY_RESPONSE
+			response.Status = yar.ERR_INTERNAL
 			response.Error = "unsupprted multi value return on rpc call"
 			return
 		}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	yar "github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/packager"
+	"github.com/weixinhost/yar.go/transports"
+)
+
+type echoService struct{}
+
+func (s *echoService) Echo(v string) string {
+	return v
+}
+
+// 在进程内启动服务，configure 用于设置 Opt，返回连接到该服务的连接
+func dialLoopback(t *testing.T, name string, configure func(s *Server)) transports.TransportConnection {
+
+	loopback := transports.NewLoopback(name)
+	t.Cleanup(func() { loopback.Close() })
+
+	loopback.OnConnection(func(conn transports.TransportConnection) {
+		s := NewServer(&echoService{})
+		s.Opt.LogLevel = 0
+		if configure != nil {
+			configure(s)
+		}
+		s.ServeConn(conn)
+	})
+
+	conn, err := loopback.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newTestRequest(method string, params ...interface{}) *yar.Request {
+	r := yar.NewRequest()
+	r.Method = method
+	r.Params = params
+	r.Protocol.Id = r.Id
+	r.Protocol.Version = yar.ProtocolVersion
+	r.Protocol.SetPackager("json")
+	return r
+}
+
+// 读取一个返回并解包，分块模式的返回边读取边解包
+func readTestResponse(t *testing.T, conn transports.TransportConnection) *yar.Response {
+
+	frame, header, err := yar.ReadFrame(conn)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := yar.NewResponse()
+	response.Protocol = header
+	body := frame[yar.HeaderLength:]
+
+	if header.HasFlag(yar.FlagChunked) {
+		err = packager.UnpackFrom(header.Packager[:], packager.NewChunkReader(bytes.NewReader(body)), response)
+	} else {
+		err = packager.Unpack(header.Packager[:], body, response)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func TestChunkedRoundTrip(t *testing.T) {
+
+	conn := dialLoopback(t, "server-chunked", func(s *Server) {
+		s.Opt.ChunkSize = 64
+	})
+
+	payload := strings.Repeat("chunk", 100)
+	r := newTestRequest("Echo", payload)
+	r.Protocol.SetFlag(yar.FlagChunked)
+	//分块模式下 BodyLength 只包含打包协议名
+	r.Protocol.BodyLength = yar.PackagerLength
+
+	if _, err := conn.Write(r.Protocol.Bytes().Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	cw := packager.NewChunkWriter(conn, 64)
+
+	if err := packager.PackTo(r.Protocol.Packager[:], cw, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	response := readTestResponse(t, conn)
+
+	if !response.Protocol.HasFlag(yar.FlagChunked) {
+		t.Fatal("response not chunked")
+	}
+
+	if response.Status != yar.ERR_OKEY || response.Id != r.Id || response.Retval != payload {
+		t.Fatal(response.Status, response.Error, response.Id)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+
+	r := newTestRequest("Echo", "hello")
+	r.Protocol.SetFlag(yar.FlagChecksum)
+
+	body, err := packager.Pack(r.Protocol.Packager[:], r)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//错误的校验值
+	body = append(body, 0, 0, 0, 0)
+	r.Protocol.BodyLength = uint32(len(body) + yar.PackagerLength)
+	frame := append(r.Protocol.Bytes().Bytes(), body...)
+
+	s := NewServer(&echoService{})
+	s.Opt.LogLevel = 0
+	output := new(bytes.Buffer)
+
+	if callErr := s.Handle(frame, output); callErr == nil || !callErr.Assert(yar.ErrorProtocol) || !strings.Contains(callErr.String(), "checksum") {
+		t.Fatal("checksum mismatch accepted", callErr)
+	}
+
+	if output.Len() > 0 {
+		t.Fatal("reply written for corrupted request")
+	}
+
+	//连接上没有返回，服务端关闭连接
+	conn := dialLoopback(t, "server-checksum", nil)
+
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := yar.ReadFrame(conn); err == nil {
+		t.Fatal("reply received for corrupted request")
+	}
+}
+
+func TestServeConnPersistent(t *testing.T) {
+
+	conn := dialLoopback(t, "server-persistent", func(s *Server) {
+		s.Opt.Persistent = true
+	})
+
+	send := func(r *yar.Request) {
+		body, err := packager.Pack(r.Protocol.Packager[:], r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = body
+		if err := conn.Send(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//同一连接上依次处理多个请求
+	for i := 0; i < 3; i++ {
+
+		tag := strings.Repeat("p", i+1)
+		r := newTestRequest("Echo", tag)
+		r.Protocol.SetFlag(yar.FlagPersistent)
+		send(r)
+
+		response := readTestResponse(t, conn)
+
+		if !response.Protocol.HasFlag(yar.FlagPersistent) || response.Id != r.Id || response.Retval != tag {
+			t.Fatal(i, response.Protocol.Reserved, response.Id, response.Retval)
+		}
+	}
+
+	//不带 FlagPersistent 的请求处理完成后关闭连接
+	send(newTestRequest("Echo", "last"))
+
+	if response := readTestResponse(t, conn); response.Retval != "last" {
+		t.Fatal(response.Retval)
+	}
+
+	if _, _, err := yar.ReadFrame(conn); err == nil {
+		t.Fatal("connection kept after non-persistent request")
+	}
+}
+
+func TestVersionRejected(t *testing.T) {
+
+	tests := []struct {
+		server  uint16
+		request uint16
+		flag    uint32
+		ok      bool
+	}{
+		//原始协议的对端不使用任何标志
+		{yar.ProtocolVersion, yar.ProtocolVersionLegacy, 0, true},
+		{yar.ProtocolVersionLegacy, yar.ProtocolVersion, 0, true},
+		//标志需要的版本高于协商的版本
+		{yar.ProtocolVersion, yar.ProtocolVersionLegacy, yar.FlagChecksum, false},
+		{yar.ProtocolVersionLegacy, yar.ProtocolVersion, yar.FlagChecksum, false},
+		{yar.ProtocolVersionFlags, yar.ProtocolVersion, yar.FlagContinuation, false},
+		{yar.ProtocolVersion, yar.ProtocolVersionFlags, yar.FlagContinuation, false},
+		{yar.ProtocolVersionFlags, yar.ProtocolVersion, yar.FlagChecksum, true},
+	}
+
+	for _, test := range tests {
+
+		r := newTestRequest("Echo", "hello")
+		r.Protocol.Version = test.request
+		r.Protocol.SetFlag(test.flag)
+
+		body, _ := packager.Pack(r.Protocol.Packager[:], r)
+		body, _ = yar.EncodeBody(r.Protocol, yar.NewOpt(), body)
+		r.Protocol.BodyLength = uint32(len(body) + yar.PackagerLength)
+
+		s := NewServer(&echoService{})
+		s.Opt.LogLevel = 0
+		s.Opt.Version = test.server
+		output := new(bytes.Buffer)
+
+		callErr := s.Handle(append(r.Protocol.Bytes().Bytes(), body...), output)
+
+		if test.ok {
+			if callErr != nil || output.Len() < yar.HeaderLength {
+				t.Fatal(test, callErr)
+			}
+			//返回使用协商的版本
+			header, _ := yar.ParseHeader(output.Bytes())
+			if header.Version != yar.NegotiateVersion(test.request, test.server) {
+				t.Fatal(test, header.Version)
+			}
+			continue
+		}
+
+		if callErr == nil || !callErr.Assert(yar.ErrorProtocol) || output.Len() > 0 {
+			t.Fatal("request accepted", test, callErr)
+		}
+	}
+}
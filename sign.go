@@ -0,0 +1,30 @@
+package yar
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// 签名模式下头部的 Token 为 HMAC-SHA256(MagicNumber + Id + 数据)，数据为实际发送的字节(压缩、加密、校验之后)
+// 签名会覆盖原有的 Token，分块与续帧模式下无法签名
+func signature(header *Header, secret string, body []byte) []byte {
+	var prefix [8]byte
+	binary.BigEndian.PutUint32(prefix[0:], uint32(header.MagicNumber))
+	binary.BigEndian.PutUint32(prefix[4:], header.Id)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(prefix[:])
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// SignHeader 计算签名写入 Token 并设置 FlagSigned
+func SignHeader(header *Header, secret string, body []byte) {
+	header.SetFlag(FlagSigned)
+	copy(header.Token[:], signature(header, secret, body))
+}
+
+// VerifyHeader 检查头部带有 FlagSigned 且签名正确
+func VerifyHeader(header *Header, secret string, body []byte) bool {
+	return header.HasFlag(FlagSigned) && hmac.Equal(header.Token[:], signature(header, secret, body))
+}
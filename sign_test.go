@@ -0,0 +1,26 @@
+package yar
+
+import "testing"
+
+func TestSignHeader(t *testing.T) {
+
+	header := NewHeader()
+	header.Id = 12
+	body := []byte(`{"i":12,"m":"echo","p":[]}`)
+
+	SignHeader(header, "secret", body)
+
+	if !VerifyHeader(header, "secret", body) {
+		t.Fatal("signature not verified")
+	}
+
+	if VerifyHeader(header, "other", body) {
+		t.Fatal("verified with wrong secret")
+	}
+
+	body[3] = '3'
+
+	if VerifyHeader(header, "secret", body) {
+		t.Fatal("verified tampered body")
+	}
+}
@@ -0,0 +1,60 @@
+package yar
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// Trailer 服务端追加在返回数据之后的耗时信息，用于定位端到端的延迟
+// 格式为 4 字节排队耗时(微秒) + 4 字节处理耗时(微秒) + 主机名 + 2 字节的 trailer 长度，均为大端
+type Trailer struct {
+	//QueueTime 收到请求到开始调用处理方法的耗时，包含解包的时间
+	QueueTime time.Duration
+	//HandlerTime 处理方法的耗时
+	HandlerTime time.Duration
+	Hostname    string
+}
+
+const trailerFixedLength = 8
+
+var ErrTrailerMalformed = errors.New("malformed trailer")
+
+func (self *Trailer) Encode() []byte {
+
+	hostname := self.Hostname
+
+	if len(hostname) > 255 {
+		hostname = hostname[:255]
+	}
+
+	n := trailerFixedLength + len(hostname)
+	data := make([]byte, n+2)
+	binary.BigEndian.PutUint32(data[0:], uint32(self.QueueTime/time.Microsecond))
+	binary.BigEndian.PutUint32(data[4:], uint32(self.HandlerTime/time.Microsecond))
+	copy(data[trailerFixedLength:], hostname)
+	binary.BigEndian.PutUint16(data[n:], uint16(n))
+	return data
+}
+
+// SplitTrailer 从数据末尾取出 trailer，返回剩余的数据
+func SplitTrailer(data []byte) ([]byte, *Trailer, error) {
+
+	if len(data) < 2 {
+		return nil, nil, ErrTrailerMalformed
+	}
+
+	n := int(binary.BigEndian.Uint16(data[len(data)-2:]))
+	start := len(data) - 2 - n
+
+	if n < trailerFixedLength || start < 0 {
+		return nil, nil, ErrTrailerMalformed
+	}
+
+	raw := data[start : len(data)-2]
+	trailer := new(Trailer)
+	trailer.QueueTime = time.Duration(binary.BigEndian.Uint32(raw[0:])) * time.Microsecond
+	trailer.HandlerTime = time.Duration(binary.BigEndian.Uint32(raw[4:])) * time.Microsecond
+	trailer.Hostname = string(raw[trailerFixedLength:])
+	return data[:start], trailer, nil
+}
@@ -0,0 +1,32 @@
+package yar
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTrailer(t *testing.T) {
+
+	trailer := new(Trailer)
+	trailer.QueueTime = 1500 * time.Microsecond
+	trailer.HandlerTime = 20 * time.Millisecond
+	trailer.Hostname = "web-1"
+
+	body := []byte(`{"i":1}`)
+	data := append(append([]byte{}, body...), trailer.Encode()...)
+
+	rest, decoded, err := SplitTrailer(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rest, body) || *decoded != *trailer {
+		t.Fatalf("unexpected %q %+v", rest, decoded)
+	}
+
+	if _, _, err = SplitTrailer(data[len(data)-3:]); err != ErrTrailerMalformed {
+		t.Fatal("expected malformed trailer")
+	}
+}
@@ -0,0 +1,7 @@
+//go:build windows || plan9
+
+package transports
+
+func (conn *SockConnection) Alive() bool {
+	return true
+}
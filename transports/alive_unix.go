@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+
+package transports
+
+import (
+	"crypto/tls"
+	"syscall"
+)
+
+// Alive 以非阻塞的 MSG_PEEK 检查空闲连接，对端已关闭或有未读数据时返回 false
+func (conn *SockConnection) Alive() bool {
+
+	raw := conn.conn
+
+	//TLS 连接检查底层的 tcp 连接，空闲时收到的任何数据(如 close_notify)都视为不可用
+	if tlsConn, ok := raw.(*tls.Conn); ok {
+		raw = tlsConn.NetConn()
+	}
+
+	sc, ok := raw.(syscall.Conn)
+
+	if !ok {
+		return true
+	}
+
+	rc, err := sc.SyscallConn()
+
+	if err != nil {
+		return false
+	}
+
+	alive := true
+	var buffer [1]byte
+
+	err = rc.Read(func(fd uintptr) bool {
+		_, _, readErr := syscall.Recvfrom(int(fd), buffer[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		//EAGAIN 表示没有数据且连接正常
+		if readErr == syscall.EAGAIN || readErr == syscall.EWOULDBLOCK {
+			return true
+		}
+		alive = false
+		return true
+	})
+
+	return err == nil && alive
+}
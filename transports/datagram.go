@@ -0,0 +1,108 @@
+package transports
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+)
+
+// 单个数据报的最大长度
+const maxDatagramSize = 1 << 16
+
+// DatagramConnection 服务端收到的一个数据报，每个数据报是一个完整的请求
+// 返回写回发送方，发送方没有绑定地址(如 unixgram 客户端)时丢弃，适合只发不收的调用
+type DatagramConnection struct {
+	conn   net.PacketConn
+	addr   net.Addr
+	reader *bytes.Reader
+}
+
+func newDatagramConnection(conn net.PacketConn, addr net.Addr, data []byte) *DatagramConnection {
+	dgram := new(DatagramConnection)
+	dgram.conn = conn
+	dgram.addr = addr
+	dgram.reader = bytes.NewReader(data)
+	return dgram
+}
+
+func (conn *DatagramConnection) Read(buffer []byte) (n int, err error) {
+	return conn.reader.Read(buffer)
+}
+
+// Write 每次写入作为一个数据报发送
+func (conn *DatagramConnection) Write(buffer []byte) (n int, err error) {
+
+	if conn.addr == nil {
+		return len(buffer), nil
+	}
+
+	if unixAddr, ok := conn.addr.(*net.UnixAddr); ok && (unixAddr == nil || len(unixAddr.Name) < 1) {
+		return len(buffer), nil
+	}
+
+	return conn.conn.WriteTo(buffer, conn.addr)
+}
+
+func (conn *DatagramConnection) Close() (err error) {
+	return nil
+}
+
+func (conn *DatagramConnection) SetReadTimeout(timeout time.Duration) {
+}
+
+func (conn *DatagramConnection) SetWriteTimeout(timeout time.Duration) {
+}
+
+func (conn *DatagramConnection) SetDeadline(deadline time.Time) error {
+	return nil
+}
+
+func (conn *DatagramConnection) RemoteAddr() net.Addr {
+	return conn.addr
+}
+
+func (conn *DatagramConnection) Send(r *yar.Request) error {
+	return send(conn, r)
+}
+
+func (conn *DatagramConnection) Recv(response *yar.Response) error {
+	return recv(conn.reader, response)
+}
+
+// udp 与 unixgram 的服务端，每个数据报交给处理函数
+func (self *Sock) serveDatagram() error {
+
+	packet, err := net.ListenPacket(self.net, self.hostname)
+
+	if err != nil {
+		return err
+	}
+
+	self.packet = packet
+	atomic.StoreInt32(&self.running, 1)
+
+	defer packet.Close()
+
+	buffer := make([]byte, maxDatagramSize)
+
+	for atomic.LoadInt32(&self.running) == 1 {
+
+		n, addr, err := packet.ReadFrom(buffer)
+
+		if err != nil {
+			if atomic.LoadInt32(&self.running) == 0 {
+				break
+			}
+			return err
+		}
+
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+		go self.handler(newDatagramConnection(packet, addr, data))
+	}
+
+	return nil
+}
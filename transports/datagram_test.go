@@ -0,0 +1,60 @@
+package transports
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+)
+
+func TestUnixgram(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "yar.sock")
+	server, _ := NewSock("unixgram", path)
+
+	bodies := make(chan string, 1)
+
+	server.OnConnection(func(conn TransportConnection) {
+		response := yar.NewResponse()
+		if err := conn.Recv(response); err != nil {
+			t.Error(err)
+			return
+		}
+		bodies <- string(response.Body)
+		//发送方没有绑定地址，返回被丢弃
+		if _, err := conn.Write([]byte("ignored")); err != nil {
+			t.Error(err)
+		}
+	})
+
+	go server.Serve()
+	defer server.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	client, _ := NewSock("unixgram", path)
+	conn, err := client.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	r := yar.NewRequest()
+	r.Protocol.Layout = yar.DefaultLayout
+	r.Body = []byte("12345678json")
+
+	if err := conn.Send(r); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case body := <-bodies:
+		if body != "12345678json" {
+			t.Fatal(body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("datagram not received")
+	}
+}
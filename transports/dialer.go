@@ -0,0 +1,124 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// DialContextFunc 建立连接的函数，可以替换为自定义的实现，如指定源地址、经过代理或使用自己的域名解析
+type DialContextFunc func(ctx context.Context, network string, address string) (net.Conn, error)
+
+var defaultDialer = new(net.Dialer)
+
+func DefaultDialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return defaultDialer.DialContext(ctx, network, address)
+}
+
+// timeout 大于 0 时为 dial 加上超时
+func dialWithTimeout(dial DialContextFunc, timeout time.Duration, network string, address string) (net.Conn, context.Context, context.CancelFunc, error) {
+
+	if dial == nil {
+		dial = DefaultDialContext
+	}
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	conn, err := dial(ctx, network, address)
+
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+
+	return conn, ctx, cancel, nil
+}
+
+// DefaultFallbackDelay DialParallel 中依次启动下一个地址的间隔
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// DialParallel 按顺序间隔 delay 依次连接 addresses(Happy Eyeballs)，前一个地址失败时立即连接下一个
+// 返回最先建立的连接，其余连接被关闭，全部失败时返回第一个错误
+func DialParallel(ctx context.Context, dial DialContextFunc, network string, addresses []string, delay time.Duration) (net.Conn, error) {
+
+	if len(addresses) < 1 {
+		return nil, errors.New("no address to dial")
+	}
+
+	if dial == nil {
+		dial = DefaultDialContext
+	}
+
+	if len(addresses) == 1 {
+		return dial(ctx, network, addresses[0])
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(addresses))
+	next, pending := 0, 0
+
+	launch := func() {
+		address := addresses[next]
+		next++
+		pending++
+		go func() {
+			conn, err := dial(ctx, network, address)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var firstErr error
+
+	for pending > 0 || next < len(addresses) {
+
+		select {
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				//关闭稍后建立的其它连接
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return result.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = result.err
+			}
+
+			if next < len(addresses) {
+				launch()
+				timer.Reset(delay)
+			}
+
+		case <-timer.C:
+			if next < len(addresses) {
+				launch()
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	return nil, firstErr
+}
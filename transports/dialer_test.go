@@ -0,0 +1,161 @@
+package transports
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialParallel(t *testing.T) {
+
+	dial := func(ctx context.Context, network string, address string) (net.Conn, error) {
+		switch address {
+		case "hang":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "refused":
+			return nil, errors.New("refused")
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+
+	start := time.Now()
+	conn, err := DialParallel(context.Background(), dial, "tcp", []string{"hang", "ok"}, 20*time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Close()
+
+	if time.Since(start) > time.Second {
+		t.Fatal("second address not tried after delay")
+	}
+
+	if _, err := DialParallel(context.Background(), dial, "tcp", []string{"refused", "refused"}, time.Hour); err == nil || err.Error() != "refused" {
+		t.Fatal(err)
+	}
+}
+
+// 挂起直到超时的 dial，以及记录调用次数后正常建立连接的 dial
+func testDialers(dialed *int32) (DialContextFunc, DialContextFunc) {
+
+	hang := func(ctx context.Context, network string, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	counting := func(ctx context.Context, network string, address string) (net.Conn, error) {
+		atomic.AddInt32(dialed, 1)
+		return DefaultDialContext(ctx, network, address)
+	}
+
+	return hang, counting
+}
+
+func TestSockDialer(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	var dialed int32
+	hang, counting := testDialers(&dialed)
+
+	sock, _ := NewSock("tcp", listener.Addr().String())
+	sock.SetDialer(counting)
+	conn, err := sock.Connection()
+
+	if err != nil || atomic.LoadInt32(&dialed) != 1 {
+		t.Fatal(dialed, err)
+	}
+
+	conn.Close()
+
+	sock.SetDialer(hang)
+	sock.SetDialTimeout(50 * time.Millisecond)
+	start := time.Now()
+
+	if _, err = sock.Connection(); err == nil || time.Since(start) > time.Second {
+		t.Fatal("dial timeout not applied", err)
+	}
+}
+
+func TestHttpClientDialer(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	//未设置 dial 时并发建立连接
+	client, _ := NewHttpClient(server.URL)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := testHttpRequest(client); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	var dialed int32
+	hang, counting := testDialers(&dialed)
+
+	client.SetDialer(counting)
+
+	if err := testHttpRequest(client); err != nil || atomic.LoadInt32(&dialed) != 1 {
+		t.Fatal(dialed, err)
+	}
+
+	client.SetDialer(hang)
+	client.SetDialTimeout(50 * time.Millisecond)
+	start := time.Now()
+
+	if err := testHttpRequest(client); err == nil || time.Since(start) > time.Second {
+		t.Fatal("dial timeout not applied", err)
+	}
+}
+
+// 在 client 上发出一次请求，服务端返回 "ok"
+func testHttpRequest(client *HttpClient) error {
+
+	conn, err := client.Connection()
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err = conn.Write([]byte("{}")); err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(conn)
+
+	if err == nil && string(body) != "ok" {
+		err = errors.New("unexpected body " + string(body))
+	}
+
+	return err
+}
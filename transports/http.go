@@ -1,10 +1,14 @@
 package transports
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"github.com/weixinhost/yar.go"
 )
 
 type HttpConnection struct {
@@ -46,6 +50,19 @@ func (conn *HttpConnection) SetWriteTimeout(timeout time.Duration) {
 	//its empty.
 }
 
+func (conn *HttpConnection) SetDeadline(deadline time.Time) error {
+	//its empty.
+	return nil
+}
+
+func (conn *HttpConnection) Send(r *yar.Request) error {
+	return send(conn.response, r)
+}
+
+func (conn *HttpConnection) Recv(response *yar.Response) error {
+	return recv(conn.request.Body, response)
+}
+
 type Http struct {
 	hostname     string
 	path         string
@@ -53,7 +70,8 @@ type Http struct {
 	handler      ConnectionHandler
 	readTimeout  time.Duration
 	writeTimeout time.Duration
-	running      bool
+	running      int32
+	sockOpt      *SocketOptions
 }
 
 func NewHttp(hostname string, path string, readTimeout time.Duration, writeTimeout time.Duration) (*Http, error) {
@@ -76,6 +94,11 @@ func (self *Http) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	self.handler(conn)
 }
 
+// SetSocketOptions 设置服务端接受的连接的 socket 参数，需在 Serve 前调用
+func (self *Http) SetSocketOptions(opt *SocketOptions) {
+	self.sockOpt = opt
+}
+
 func (self *Http) Serve() (err error) {
 
 	s := &http.Server{
@@ -83,11 +106,31 @@ func (self *Http) Serve() (err error) {
 		ReadTimeout:  self.readTimeout,
 		WriteTimeout: self.writeTimeout,
 		Handler:      self,
+		//为接受的连接设置 socket 参数
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			self.sockOpt.Apply(conn)
+			return ctx
+		},
 	}
 
 	s.SetKeepAlivesEnabled(false)
-	err = s.ListenAndServe()
-	fmt.Print(err)
+
+	listener, err := net.Listen("tcp", self.hostname)
+
+	if err != nil {
+		fmt.Print(err)
+		return nil
+	}
+
+	self.listener = listener
+	atomic.StoreInt32(&self.running, 1)
+	err = s.Serve(listener)
+
+	//Close 之后返回的错误不再输出
+	if atomic.LoadInt32(&self.running) == 1 {
+		fmt.Print(err)
+	}
+
 	return nil
 }
 
@@ -109,6 +152,17 @@ func (self *Http) initConnection(conn net.Conn) {
 
 func (self *Http) Stop() {
 
-	self.running = false
+	atomic.StoreInt32(&self.running, 0)
+
+}
+
+func (self *Http) Close() error {
+
+	self.Stop()
 
+	if self.listener != nil {
+		return self.listener.Close()
+	}
+
+	return nil
 }
@@ -0,0 +1,334 @@
+package transports
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+)
+
+// HttpClient 客户端使用的 http 传输，每个连接对应一次 POST 请求
+// 写出的数据通过管道作为请求体发送，第一次读取时结束请求体并等待返回
+type HttpClient struct {
+	url         string
+	lock        sync.Mutex
+	tlsConfig   *tls.Config
+	serverName  string
+	rootCAs     *x509.CertPool
+	dialContext DialContextFunc
+	dialTimeout time.Duration
+	sockOpt     *SocketOptions
+	transport   *http.Transport
+	labels      map[string]string
+	readLimit   *RateLimiter
+	writeLimit  *RateLimiter
+}
+
+func NewHttpClient(url string) (*HttpClient, error) {
+	client := new(HttpClient)
+	client.url = url
+	network := "http"
+	if strings.HasPrefix(strings.ToLower(url), "https://") {
+		network = "https"
+	}
+	client.labels = transportLabels("client", network, url)
+	return client, nil
+}
+
+func (self *HttpClient) Serve() (err error) {
+	return errors.New("http client transport can not serve")
+}
+
+func (self *HttpClient) OnConnection(handler ConnectionHandler) {
+}
+
+func (self *HttpClient) Connection() (conn TransportConnection, err error) {
+
+	self.lock.Lock()
+
+	if self.transport == nil {
+		tlsConfig := self.tlsConfig
+		if tlsConfig == nil {
+			//todo 停止验证HTTPS请求
+			tlsConfig = &tls.Config{InsecureSkipVerify: true}
+		}
+		tlsConfig = withServerName(tlsConfig, self.serverName, self.rootCAs)
+		self.transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
+		}
+		self.transport.DisableKeepAlives = true
+		dial, timeout, sockOpt, labels := self.dialContext, self.dialTimeout, self.sockOpt, self.labels
+		if dial == nil {
+			dial = DefaultDialContext
+		}
+		self.transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			start := time.Now()
+			conn, err := dial(ctx, network, address)
+			observeDial(labels, start, err)
+			if err != nil {
+				return nil, err
+			}
+			if err = sockOpt.Apply(conn); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+	}
+
+	conn = newHttpClientConnection(self, self.transport)
+	self.lock.Unlock()
+	return conn, nil
+}
+
+// SetDialer 替换建立 tcp 连接的函数，为空时使用 net.Dialer
+func (self *HttpClient) SetDialer(dial DialContextFunc) {
+	self.lock.Lock()
+	self.dialContext = dial
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+// SetDialTimeout 建立 tcp 连接的超时时间，为 0 表示不限制
+func (self *HttpClient) SetDialTimeout(timeout time.Duration) {
+	self.lock.Lock()
+	self.dialTimeout = timeout
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+// SetSocketOptions 设置 tcp 连接的 socket 参数，传入 nil 保持默认
+func (self *HttpClient) SetSocketOptions(opt *SocketOptions) {
+	self.lock.Lock()
+	self.sockOpt = opt
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+// SetThrottle 限制请求体与返回的读写速度(字节/秒)，为 0 表示不限速
+func (self *HttpClient) SetThrottle(readRate int, writeRate int) {
+	self.lock.Lock()
+	self.readLimit = newRateLimiter(readRate)
+	self.writeLimit = newRateLimiter(writeRate)
+	self.lock.Unlock()
+}
+
+// SetServerName 设置 https 的 SNI 及验证证书的主机名，不改变是否验证证书：未设置 TLS 配置及 CA 时仍不验证
+func (self *HttpClient) SetServerName(name string) {
+	self.lock.Lock()
+	self.serverName = name
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+// SetRootCAs 设置验证 https 服务端证书的 CA，设置后总是验证服务端证书
+func (self *HttpClient) SetRootCAs(pool *x509.CertPool) {
+	self.lock.Lock()
+	self.rootCAs = pool
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+// SetTLSConfig 设置 https 使用的 TLS 配置，未设置时不验证服务端证书
+func (self *HttpClient) SetTLSConfig(config *tls.Config) {
+	self.lock.Lock()
+	self.tlsConfig = config
+	self.transport = nil
+	self.lock.Unlock()
+}
+
+func (self *HttpClient) Close() error {
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if self.transport != nil {
+		self.transport.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+type httpResult struct {
+	response *http.Response
+	err      error
+}
+
+type HttpClientConnection struct {
+	client    *HttpClient
+	transport *http.Transport
+	deadline  time.Time
+	writer    *io.PipeWriter
+	result    chan httpResult
+	body      io.ReadCloser
+	err       error
+	cancel    context.CancelFunc
+}
+
+func newHttpClientConnection(client *HttpClient, transport *http.Transport) *HttpClientConnection {
+	conn := new(HttpClientConnection)
+	conn.client = client
+	conn.transport = transport
+	return conn
+}
+
+// 第一次写入时发起请求
+func (conn *HttpClientConnection) start() {
+
+	reader, writer := io.Pipe()
+	conn.writer = writer
+	transport := conn.transport
+	conn.result = make(chan httpResult, 1)
+
+	ctx := context.Background()
+
+	if !conn.deadline.IsZero() {
+		ctx, conn.cancel = context.WithDeadline(ctx, conn.deadline)
+	}
+
+	go func() {
+
+		request, err := http.NewRequest("POST", conn.client.url, reader)
+
+		if err != nil {
+			reader.CloseWithError(err)
+			conn.result <- httpResult{err: err}
+			return
+		}
+
+		request = request.WithContext(ctx)
+		request.Header.Set("Content-Type", "application/json")
+		response, err := (&http.Client{Transport: transport}).Do(request)
+		//返回后不再读取请求体，避免写入方阻塞
+		reader.CloseWithError(io.ErrClosedPipe)
+		conn.result <- httpResult{response: response, err: err}
+	}()
+}
+
+func (conn *HttpClientConnection) Write(buffer []byte) (n int, err error) {
+	if err = conn.client.writeLimit.Wait(len(buffer), conn.deadline); err != nil {
+		return 0, err
+	}
+
+	n, err = conn.write(buffer)
+	observeWrite(conn.client.labels, n, err)
+	return n, err
+}
+
+func (conn *HttpClientConnection) write(buffer []byte) (n int, err error) {
+
+	if conn.body != nil || conn.err != nil {
+		return 0, errors.New("http request already sent")
+	}
+
+	if conn.writer == nil {
+		conn.start()
+	}
+
+	n, err = conn.writer.Write(buffer)
+
+	//请求体被提前关闭时，返回请求本身的错误（如连接失败）
+	if err == io.ErrClosedPipe {
+		result := <-conn.result
+		if result.err != nil {
+			conn.err = result.err
+			return n, result.err
+		}
+		conn.result <- result
+	}
+
+	return n, err
+}
+
+func (conn *HttpClientConnection) Read(buffer []byte) (n int, err error) {
+	n, err = conn.read(buffer)
+	observeRead(conn.client.labels, n, err)
+	if waitErr := conn.client.readLimit.Wait(n, conn.deadline); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	return n, err
+}
+
+func (conn *HttpClientConnection) read(buffer []byte) (n int, err error) {
+
+	if conn.body == nil && conn.err == nil {
+
+		if conn.writer == nil {
+			conn.start()
+		}
+
+		conn.writer.Close()
+		result := <-conn.result
+
+		if result.err != nil {
+			conn.err = result.err
+		} else {
+			conn.body = result.response.Body
+		}
+	}
+
+	if conn.err != nil {
+		return 0, conn.err
+	}
+
+	return conn.body.Read(buffer)
+}
+
+func (conn *HttpClientConnection) Close() (err error) {
+
+	if conn.writer != nil {
+		conn.writer.Close()
+	}
+
+	if conn.body == nil && conn.err == nil && conn.result != nil {
+		if result := <-conn.result; result.err == nil {
+			conn.body = result.response.Body
+		}
+	}
+
+	if conn.body != nil {
+		err = conn.body.Close()
+	}
+
+	if conn.cancel != nil {
+		conn.cancel()
+	}
+
+	return err
+}
+
+func (conn *HttpClientConnection) SetReadTimeout(timeout time.Duration) {
+	conn.SetDeadline(time.Now().Add(timeout))
+}
+
+func (conn *HttpClientConnection) SetWriteTimeout(timeout time.Duration) {
+	conn.SetDeadline(time.Now().Add(timeout))
+}
+
+// SetDeadline 作用于整个请求，需要在第一次写入之前设置
+func (conn *HttpClientConnection) SetDeadline(deadline time.Time) error {
+	conn.deadline = deadline
+	return nil
+}
+
+func (conn *HttpClientConnection) Send(r *yar.Request) error {
+	return send(conn, r)
+}
+
+func (conn *HttpClientConnection) Recv(response *yar.Response) error {
+	return recv(conn, response)
+}
@@ -0,0 +1,71 @@
+package transports
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// ListenFunc 创建监听的函数，用于替换 net.Listen
+type ListenFunc func(network string, address string) (net.Listener, error)
+
+// KCP(可靠 UDP)传输的接入点，适合高延迟、丢包较多的跨地域链路，实验性
+// 本包不实现 KCP 协议，也不依赖具体的实现：未调用 RegisterKCP 时 kcp:// 地址返回 "kcp is not registered" 错误
+// 需由使用方引入 KCP 的实现并注册，如 github.com/xtaci/kcp-go：
+//
+//	transports.RegisterKCP(func(ctx context.Context, network string, address string) (net.Conn, error) {
+//		return kcp.Dial(address)
+//	}, func(network string, address string) (net.Listener, error) {
+//		return kcp.Listen(address)
+//	})
+var kcpDriver struct {
+	lock   sync.RWMutex
+	dial   DialContextFunc
+	listen ListenFunc
+}
+
+// kcp:// 地址始终可以解析，未注册实现时在创建传输时返回错误
+func init() {
+	Register("kcp", func(address string) (Transport, error) {
+
+		sock, err := NewKCP(address)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return sock, nil
+	})
+}
+
+// RegisterKCP 注册 KCP 的实现，注册后 client.NewClient 可以使用 kcp:// 地址
+func RegisterKCP(dial DialContextFunc, listen ListenFunc) {
+
+	kcpDriver.lock.Lock()
+	kcpDriver.dial = dial
+	kcpDriver.listen = listen
+	kcpDriver.lock.Unlock()
+}
+
+// NewKCP 创建 KCP 传输，客户端连接在池中复用，服务端与 tcp 一样使用 OnConnection 处理连接
+func NewKCP(address string) (*Sock, error) {
+
+	kcpDriver.lock.RLock()
+	dial, listen := kcpDriver.dial, kcpDriver.listen
+	kcpDriver.lock.RUnlock()
+
+	if dial == nil || listen == nil {
+		return nil, errors.New("kcp is not registered, see transports.RegisterKCP")
+	}
+
+	sock, err := NewSock("kcp", address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sock.SetDialer(dial)
+	sock.SetListener(listen)
+	sock.SetPool(NewPoolConfig())
+	return sock, nil
+}
@@ -0,0 +1,69 @@
+package transports
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKCPRegistration(t *testing.T) {
+
+	if _, err := NewKCP("127.0.0.1:15661"); err == nil {
+		t.Fatal("kcp created without registration")
+	}
+
+	factory, ok := Lookup("kcp")
+
+	if !ok {
+		t.Fatal("kcp scheme not registered")
+	}
+
+	if transport, err := factory("127.0.0.1:15661"); err == nil || transport != nil || !strings.Contains(err.Error(), "kcp is not registered") {
+		t.Fatal(transport, err)
+	}
+
+	//以 tcp 代替 KCP 的实现
+	RegisterKCP(func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return DefaultDialContext(ctx, "tcp", address)
+	}, func(network string, address string) (net.Listener, error) {
+		return net.Listen("tcp", address)
+	})
+	defer func() {
+		kcpDriver.lock.Lock()
+		kcpDriver.dial, kcpDriver.listen = nil, nil
+		kcpDriver.lock.Unlock()
+	}()
+
+	server, err := NewKCP("127.0.0.1:15661")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := make(chan bool, 1)
+	server.OnConnection(func(conn TransportConnection) {
+		conn.Close()
+		accepted <- true
+	})
+
+	go server.Serve()
+	defer server.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	client, _ := factory("127.0.0.1:15661")
+	conn, err := client.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("connection not accepted")
+	}
+}
@@ -0,0 +1,97 @@
+package transports
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var loopbacks = struct {
+	lock      sync.Mutex
+	listeners map[string]*Loopback
+}{listeners: make(map[string]*Loopback)}
+
+func init() {
+	//client.NewClient("loopback://name") 连接到同名的 Loopback
+	Register("loopback", func(address string) (Transport, error) {
+		loopbacks.lock.Lock()
+		defer loopbacks.lock.Unlock()
+		loopback, ok := loopbacks.listeners[address]
+		if !ok {
+			return nil, errors.New("loopback not found: " + address)
+		}
+		return loopback, nil
+	})
+}
+
+// Loopback 进程内的传输，每个连接是一对内存管道，另一端交给 OnConnection 设置的处理函数
+// 服务端与网络传输使用同样的处理代码，便于单元测试和进程内调用
+type Loopback struct {
+	name    string
+	lock    sync.RWMutex
+	handler ConnectionHandler
+	closed  bool
+
+	clientLabels map[string]string
+	serverLabels map[string]string
+}
+
+// NewLoopback 创建名为 name 的进程内传输，同名的旧传输被替换
+func NewLoopback(name string) *Loopback {
+
+	loopback := new(Loopback)
+	loopback.name = name
+	loopback.handler = defaultHandler
+	loopback.clientLabels = transportLabels("client", "loopback", name)
+	loopback.serverLabels = transportLabels("server", "loopback", name)
+
+	loopbacks.lock.Lock()
+	loopbacks.listeners[name] = loopback
+	loopbacks.lock.Unlock()
+
+	return loopback
+}
+
+func (self *Loopback) OnConnection(handler ConnectionHandler) {
+	self.lock.Lock()
+	self.handler = handler
+	self.lock.Unlock()
+}
+
+// Serve 不需要监听，直接返回
+func (self *Loopback) Serve() (err error) {
+	return nil
+}
+
+func (self *Loopback) Connection() (conn TransportConnection, err error) {
+
+	self.lock.RLock()
+	handler, closed := self.handler, self.closed
+	self.lock.RUnlock()
+
+	if closed {
+		return nil, errors.New("loopback closed: " + self.name)
+	}
+
+	client, server := net.Pipe()
+	serverConn, clientConn := newSockConnection(server), newSockConnection(client)
+	serverConn.labels, clientConn.labels = self.serverLabels, self.clientLabels
+	go handler(serverConn)
+	return clientConn, nil
+}
+
+// Close 之后不再接受新的连接，已建立的连接不受影响
+func (self *Loopback) Close() error {
+
+	self.lock.Lock()
+	self.closed = true
+	self.lock.Unlock()
+
+	loopbacks.lock.Lock()
+	if loopbacks.listeners[self.name] == self {
+		delete(loopbacks.listeners, self.name)
+	}
+	loopbacks.lock.Unlock()
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package transports
+
+import (
+	"io"
+	"time"
+
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+// 上报的指标：
+// yar.transport.dial           建立的连接数
+// yar.transport.dial.duration  建立连接(包括 TLS 握手)的耗时
+// yar.transport.dial.errors    建立连接失败次数
+// yar.transport.reuse          从连接池中复用的连接数
+// yar.transport.ping.errors    连接池中探测失败而关闭的连接数
+// yar.transport.bytes.in       读取的字节数
+// yar.transport.bytes.out      写出的字节数
+// yar.transport.read.errors    读取失败次数(不包括对端正常关闭)
+// yar.transport.write.errors   写出失败次数
+// 标签 role 为 client 或 server，net 与 address 为传输的协议及地址
+func transportLabels(role string, network string, address string) map[string]string {
+	return map[string]string{"role": role, "net": network, "address": address}
+}
+
+func observeDial(labels map[string]string, start time.Time, err error) {
+
+	if err != nil {
+		metrics.Counter("yar.transport.dial.errors", 1, labels)
+		return
+	}
+
+	metrics.Counter("yar.transport.dial", 1, labels)
+	metrics.Since("yar.transport.dial.duration", start, labels)
+}
+
+func observeRead(labels map[string]string, n int, err error) {
+
+	if n > 0 {
+		metrics.Counter("yar.transport.bytes.in", int64(n), labels)
+	}
+
+	if err != nil && err != io.EOF {
+		metrics.Counter("yar.transport.read.errors", 1, labels)
+	}
+}
+
+func observeWrite(labels map[string]string, n int, err error) {
+
+	if n > 0 {
+		metrics.Counter("yar.transport.bytes.out", int64(n), labels)
+	}
+
+	if err != nil {
+		metrics.Counter("yar.transport.write.errors", 1, labels)
+	}
+}
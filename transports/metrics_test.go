@@ -0,0 +1,95 @@
+package transports
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/weixinhost/yar.go/metrics/metricstest"
+)
+
+func TestTransportMetrics(t *testing.T) {
+
+	recorder, restore := metricstest.Install()
+	defer restore()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+
+	address := listener.Addr().String()
+	labels := map[string]string{"role": "client", "net": "tcp", "address": address}
+
+	sock, _ := NewSock("tcp", address)
+	sock.SetPool(NewPoolConfig())
+	defer sock.Close()
+
+	conn, err := sock.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Sum("yar.transport.dial", labels) != 1 || len(recorder.Records("yar.transport.dial.duration", labels)) != 1 {
+		t.Fatal(recorder.Records("yar.transport.dial", nil))
+	}
+
+	if _, err = conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Sum("yar.transport.bytes.out", labels) != 5 || recorder.Sum("yar.transport.bytes.in", labels) != 5 {
+		t.Fatal(recorder.Records("yar.transport.bytes.out", nil), recorder.Records("yar.transport.bytes.in", nil))
+	}
+
+	//归还后再次取出复用同一连接
+	sock.Release(conn)
+
+	if conn, err = sock.Connection(); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Sum("yar.transport.reuse", labels) != 1 || recorder.Sum("yar.transport.dial", labels) != 1 {
+		t.Fatal(recorder.Records("yar.transport.reuse", nil))
+	}
+
+	conn.Close()
+
+	if _, err = conn.Write([]byte("x")); err == nil {
+		t.Fatal("write on closed connection")
+	}
+
+	if recorder.Sum("yar.transport.write.errors", labels) != 1 {
+		t.Fatal(recorder.Records("yar.transport.write.errors", nil))
+	}
+
+	//地址不可用时上报建立连接失败
+	listener.Close()
+	failed, _ := NewSock("tcp", address)
+
+	if _, err = failed.Connection(); err == nil {
+		t.Fatal("dial to closed listener")
+	}
+
+	if recorder.Sum("yar.transport.dial.errors", labels) != 1 {
+		t.Fatal(recorder.Records("yar.transport.dial.errors", nil))
+	}
+}
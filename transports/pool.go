@@ -0,0 +1,285 @@
+package transports
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/metrics"
+)
+
+type PoolConfig struct {
+	//MinIdle 保持的最少空闲连接数，不足时在后台补充
+	MinIdle int
+	//MaxIdle 最多保留的空闲连接数，超出的连接直接关闭，为 0 表示不复用连接
+	MaxIdle int
+	//MaxLifetime 连接从建立起的最长使用时间，为 0 表示不限制
+	MaxLifetime time.Duration
+	//IdleTimeout 连接的最长空闲时间，为 0 表示不限制
+	IdleTimeout time.Duration
+	//Validate 取出空闲连接时检查连接是否可用，为空时只检查对端是否已关闭
+	Validate func(conn TransportConnection) bool
+	//KeepAlive 空闲连接的探测间隔，为 0 表示不探测，需要同时设置 Ping
+	KeepAlive time.Duration
+	//Ping 在连接上发送探测帧并等待回复，返回错误的连接被关闭
+	Ping func(conn TransportConnection) error
+}
+
+func NewPoolConfig() *PoolConfig {
+	config := new(PoolConfig)
+	config.MinIdle = 0
+	config.MaxIdle = 8
+	config.MaxLifetime = 10 * time.Minute
+	//服务端默认 5 秒后断开连接
+	config.IdleTimeout = 4 * time.Second
+	config.Validate = nil
+	config.KeepAlive = 0
+	config.Ping = nil
+	return config
+}
+
+// PoolConnection 连接池建立的连接，记录建立的时间
+// 直接 Close 会关闭连接，归还连接需要调用 Pool.Put
+type PoolConnection struct {
+	TransportConnection
+	created time.Time
+	idle    time.Time
+}
+
+func (pc *PoolConnection) sendBefore(r *yar.Request, deadline time.Time) error {
+	return SendBefore(pc.TransportConnection, r, deadline)
+}
+
+// Pool 单个地址上的连接池
+type Pool struct {
+	config  *PoolConfig
+	dial    func() (TransportConnection, error)
+	lock    sync.Mutex
+	idle    []*PoolConnection
+	filling int
+	closed  bool
+	labels  map[string]string
+	done    chan struct{}
+}
+
+func NewPool(config *PoolConfig, dial func() (TransportConnection, error)) *Pool {
+	pool := new(Pool)
+	pool.config = config
+	pool.dial = dial
+	pool.fill()
+
+	if config.KeepAlive > 0 && config.Ping != nil {
+		pool.done = make(chan struct{})
+		go pool.keepalive()
+	}
+
+	return pool
+}
+
+// Get 取出一个可用的空闲连接，没有时建立新连接
+func (pool *Pool) Get() (TransportConnection, error) {
+
+	for {
+
+		pool.lock.Lock()
+
+		n := len(pool.idle)
+
+		if n < 1 {
+			pool.lock.Unlock()
+			break
+		}
+
+		pc := pool.idle[n-1]
+		pool.idle = pool.idle[:n-1]
+		pool.lock.Unlock()
+		pool.fill()
+
+		if pool.usable(pc) {
+			metrics.Counter("yar.transport.reuse", 1, pool.labels)
+			return pc, nil
+		}
+
+		pc.Close()
+	}
+
+	return pool.open()
+}
+
+// Put 归还连接，连接不是由该池建立、已过期、池已满或已关闭时关闭该连接
+func (pool *Pool) Put(conn TransportConnection) {
+
+	pc, ok := conn.(*PoolConnection)
+
+	if !ok {
+		conn.Close()
+		return
+	}
+
+	now := time.Now()
+	expired := pool.config.MaxLifetime > 0 && now.Sub(pc.created) > pool.config.MaxLifetime
+
+	pool.lock.Lock()
+
+	if pool.closed || expired || len(pool.idle) >= pool.config.MaxIdle {
+		pool.lock.Unlock()
+		pc.Close()
+		return
+	}
+
+	pc.idle = now
+	pool.idle = append(pool.idle, pc)
+	pool.lock.Unlock()
+}
+
+func (pool *Pool) Config() *PoolConfig {
+	return pool.config
+}
+
+func (pool *Pool) Idle() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return len(pool.idle)
+}
+
+func (pool *Pool) Close() error {
+
+	pool.lock.Lock()
+	idle := pool.idle
+	pool.idle = nil
+
+	if !pool.closed && pool.done != nil {
+		close(pool.done)
+	}
+
+	pool.closed = true
+	pool.lock.Unlock()
+
+	for _, pc := range idle {
+		pc.Close()
+	}
+
+	return nil
+}
+
+func (pool *Pool) open() (TransportConnection, error) {
+
+	conn, err := pool.dial()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pc := new(PoolConnection)
+	pc.TransportConnection = conn
+	pc.created = time.Now()
+	return pc, nil
+}
+
+func (pool *Pool) usable(pc *PoolConnection) bool {
+
+	now := time.Now()
+
+	if pool.config.MaxLifetime > 0 && now.Sub(pc.created) > pool.config.MaxLifetime {
+		return false
+	}
+
+	if pool.config.IdleTimeout > 0 && now.Sub(pc.idle) > pool.config.IdleTimeout {
+		return false
+	}
+
+	if pool.config.Validate != nil {
+		return pool.config.Validate(pc.TransportConnection)
+	}
+
+	if alive, ok := pc.TransportConnection.(interface{ Alive() bool }); ok {
+		return alive.Alive()
+	}
+
+	return true
+}
+
+// 空闲连接少于 MinIdle 时在后台建立新的连接
+func (pool *Pool) fill() {
+
+	pool.lock.Lock()
+	need := pool.config.MinIdle - len(pool.idle) - pool.filling
+
+	if pool.closed || need <= 0 {
+		pool.lock.Unlock()
+		return
+	}
+
+	pool.filling += need
+	pool.lock.Unlock()
+
+	for i := 0; i < need; i++ {
+		go func() {
+			conn, err := pool.open()
+
+			pool.lock.Lock()
+			pool.filling--
+			pool.lock.Unlock()
+
+			if err == nil {
+				pool.Put(conn)
+			}
+		}()
+	}
+}
+
+// 定期探测空闲超过 KeepAlive 的连接，对端已失效的连接在被取出前关闭
+func (pool *Pool) keepalive() {
+
+	ticker := time.NewTicker(pool.config.KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pool.done:
+			return
+		case <-ticker.C:
+			pool.probe()
+		}
+	}
+}
+
+func (pool *Pool) probe() {
+
+	now := time.Now()
+	var probing []*PoolConnection
+
+	pool.lock.Lock()
+
+	idle := pool.idle[:0]
+
+	for _, pc := range pool.idle {
+		if now.Sub(pc.idle) >= pool.config.KeepAlive {
+			probing = append(probing, pc)
+		} else {
+			idle = append(idle, pc)
+		}
+	}
+
+	pool.idle = idle
+	pool.lock.Unlock()
+
+	for _, pc := range probing {
+
+		if pool.config.MaxLifetime > 0 && now.Sub(pc.created) > pool.config.MaxLifetime {
+			pc.Close()
+			continue
+		}
+
+		if err := pool.config.Ping(pc.TransportConnection); err != nil {
+			metrics.Counter("yar.transport.ping.errors", 1, pool.labels)
+			pc.Close()
+			continue
+		}
+
+		//对端收到探测帧后会刷新超时时间，连接重新开始计算空闲时间
+		pool.Put(pc)
+	}
+
+	pool.fill()
+}
@@ -0,0 +1,70 @@
+package transports
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPoolReuse(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 4)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	sock, _ := NewSock("tcp", listener.Addr().String())
+	sock.SetPool(NewPoolConfig())
+	defer sock.Close()
+
+	first, err := sock.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := <-accepted
+	sock.Release(first)
+
+	second, _ := sock.Connection()
+
+	if second != first {
+		t.Fatal("idle connection not reused")
+	}
+
+	//对端关闭后取出时应当丢弃该连接并重新建立
+	sock.Release(second)
+	server.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	third, err := sock.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if third == second {
+		t.Fatal("closed connection reused")
+	}
+
+	third.Close()
+
+	if sock.Pool().Idle() != 0 {
+		t.Fatal("unexpected idle connections")
+	}
+}
@@ -0,0 +1,289 @@
+package transports
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PROXY 协议(haproxy)，在 tcp 连接最开始传递真实的客户端地址
+// http://www.haproxy.org/download/1.8/doc/proxy-protocol.txt
+
+type ProxyMode int
+
+const (
+	//ProxyOff 不解析 PROXY 头
+	ProxyOff ProxyMode = iota
+	//ProxyOptional 有 PROXY 头时解析，没有时按普通连接处理
+	ProxyOptional
+	//ProxyRequired 连接必须以 PROXY 头开始
+	ProxyRequired
+)
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	ErrProxyMissing   = errors.New("proxy protocol header missing")
+	ErrProxyMalformed = errors.New("proxy protocol header malformed")
+)
+
+// ProxyHeader Version 为 1(文本)或 2(二进制)
+// Source 与 Destination 为空时表示未知地址(v1 的 UNKNOWN，v2 的 LOCAL)
+type ProxyHeader struct {
+	Version     int
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// WriteProxyHeader 写出 PROXY 头，只支持 tcp 地址
+func WriteProxyHeader(w io.Writer, header *ProxyHeader) error {
+
+	src, srcOk := header.Source.(*net.TCPAddr)
+	dst, dstOk := header.Destination.(*net.TCPAddr)
+	known := srcOk && dstOk
+	ipv4 := known && src.IP.To4() != nil && dst.IP.To4() != nil
+
+	var buffer bytes.Buffer
+
+	switch header.Version {
+	case 1:
+		if !known {
+			buffer.WriteString("PROXY UNKNOWN\r\n")
+			break
+		}
+		family := "TCP6"
+		if ipv4 {
+			family = "TCP4"
+		}
+		fmt.Fprintf(&buffer, "PROXY %s %s %s %d %d\r\n", family, src.IP.String(), dst.IP.String(), src.Port, dst.Port)
+	case 2:
+		buffer.Write(proxyV2Signature)
+		if !known {
+			//LOCAL 命令，没有地址
+			buffer.Write([]byte{0x20, 0x00, 0x00, 0x00})
+			break
+		}
+		var ports [4]byte
+		binary.BigEndian.PutUint16(ports[0:2], uint16(src.Port))
+		binary.BigEndian.PutUint16(ports[2:4], uint16(dst.Port))
+		if ipv4 {
+			buffer.Write([]byte{0x21, 0x11, 0x00, 12})
+			buffer.Write(src.IP.To4())
+			buffer.Write(dst.IP.To4())
+		} else {
+			buffer.Write([]byte{0x21, 0x21, 0x00, 36})
+			buffer.Write(src.IP.To16())
+			buffer.Write(dst.IP.To16())
+		}
+		buffer.Write(ports[:])
+	default:
+		return fmt.Errorf("unsupported proxy protocol version %d", header.Version)
+	}
+
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+// ReadProxyHeader 从 r 中读取 PROXY 头，数据不是以 PROXY 头开始时返回 ErrProxyMissing 且不消耗数据
+func ReadProxyHeader(r *bufio.Reader) (*ProxyHeader, error) {
+
+	prefix, err := r.Peek(len(proxyV2Signature))
+
+	if bytes.HasPrefix(prefix, []byte("PROXY ")) {
+		return readProxyV1(r)
+	}
+
+	if err == nil && bytes.Equal(prefix, proxyV2Signature) {
+		return readProxyV2(r)
+	}
+
+	//数据不足以判断时返回读取的错误
+	if err != nil && (bytes.HasPrefix(proxyV2Signature, prefix) || bytes.HasPrefix([]byte("PROXY "), prefix)) {
+		return nil, err
+	}
+
+	return nil, ErrProxyMissing
+}
+
+func readProxyV1(r *bufio.Reader) (*ProxyHeader, error) {
+
+	//v1 的头部最长 107 字节
+	var line []byte
+
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrProxyMalformed
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	header := &ProxyHeader{Version: 1}
+
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrProxyMalformed
+	}
+
+	src, err := proxyTCPAddr(fields[2], fields[4])
+
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := proxyTCPAddr(fields[3], fields[5])
+
+	if err != nil {
+		return nil, err
+	}
+
+	header.Source = src
+	header.Destination = dst
+	return header, nil
+}
+
+func proxyTCPAddr(ip string, port string) (*net.TCPAddr, error) {
+
+	addr := net.ParseIP(ip)
+	p, err := strconv.ParseUint(port, 10, 16)
+
+	if addr == nil || err != nil {
+		return nil, ErrProxyMalformed
+	}
+
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+func readProxyV2(r *bufio.Reader) (*ProxyHeader, error) {
+
+	var fixed [16]byte
+
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+
+	if fixed[12]>>4 != 2 {
+		return nil, ErrProxyMalformed
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	header := &ProxyHeader{Version: 2}
+
+	//LOCAL 命令(如健康检查)及其它协议族没有可用的地址，其后的 TLV 忽略
+	if fixed[12]&0x0F != 1 {
+		return header, nil
+	}
+
+	switch fixed[13] {
+	case 0x11:
+		if len(payload) < 12 {
+			return nil, ErrProxyMalformed
+		}
+		header.Source = &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}
+		header.Destination = &net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}
+	case 0x21:
+		if len(payload) < 36 {
+			return nil, ErrProxyMalformed
+		}
+		header.Source = &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}
+		header.Destination = &net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}
+	}
+
+	return header, nil
+}
+
+// proxyListener 接受的连接在第一次读取或获取地址时解析 PROXY 头，不阻塞 Accept
+type proxyListener struct {
+	net.Listener
+	mode ProxyMode
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+
+	conn, err := l.Listener.Accept()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pc := new(proxyConn)
+	pc.Conn = conn
+	pc.mode = l.mode
+	pc.reader = bufio.NewReader(conn)
+	return pc, nil
+}
+
+type proxyConn struct {
+	net.Conn
+	mode   ProxyMode
+	reader *bufio.Reader
+	once   sync.Once
+	header *ProxyHeader
+	err    error
+}
+
+func (conn *proxyConn) parse() {
+	conn.once.Do(func() {
+		//读取超时沿用连接上已设置的截止时间
+		conn.header, conn.err = ReadProxyHeader(conn.reader)
+		if conn.err == ErrProxyMissing && conn.mode == ProxyOptional {
+			conn.err = nil
+		}
+	})
+}
+
+func (conn *proxyConn) Read(buffer []byte) (int, error) {
+
+	conn.parse()
+
+	if conn.err != nil {
+		return 0, conn.err
+	}
+
+	return conn.reader.Read(buffer)
+}
+
+func (conn *proxyConn) RemoteAddr() net.Addr {
+
+	conn.parse()
+
+	if conn.header != nil && conn.header.Source != nil {
+		return conn.header.Source
+	}
+
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *proxyConn) LocalAddr() net.Addr {
+
+	conn.parse()
+
+	if conn.header != nil && conn.header.Destination != nil {
+		return conn.header.Destination
+	}
+
+	return conn.Conn.LocalAddr()
+}
@@ -0,0 +1,95 @@
+package transports
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProxyHeaderRoundTrip(t *testing.T) {
+
+	addrs := [][2]*net.TCPAddr{
+		{{IP: net.ParseIP("192.168.1.10"), Port: 5321}, {IP: net.ParseIP("10.0.0.1"), Port: 5600}},
+		{{IP: net.ParseIP("2001:db8::1"), Port: 5321}, {IP: net.ParseIP("2001:db8::2"), Port: 5600}},
+	}
+
+	for _, version := range []int{1, 2} {
+		for _, addr := range addrs {
+
+			var buffer bytes.Buffer
+
+			if err := WriteProxyHeader(&buffer, &ProxyHeader{Version: version, Source: addr[0], Destination: addr[1]}); err != nil {
+				t.Fatal(err)
+			}
+
+			buffer.WriteString("frame")
+			r := bufio.NewReader(&buffer)
+			header, err := ReadProxyHeader(r)
+
+			if err != nil {
+				t.Fatal(version, err)
+			}
+
+			if header.Version != version || header.Source.String() != addr[0].String() || header.Destination.String() != addr[1].String() {
+				t.Fatal(version, header.Source, header.Destination)
+			}
+
+			if rest, _ := r.ReadString(0); rest != "frame" {
+				t.Fatal("header not fully consumed", rest)
+			}
+		}
+	}
+}
+
+func TestProxyHeaderMissing(t *testing.T) {
+
+	r := bufio.NewReader(bytes.NewReader(bytes.Repeat([]byte{0}, 90)))
+
+	if _, err := ReadProxyHeader(r); err != ErrProxyMissing {
+		t.Fatal(err)
+	}
+
+	if r.Buffered() != 90 {
+		t.Fatal("data consumed")
+	}
+}
+
+func TestSockProxyProtocol(t *testing.T) {
+
+	server, _ := NewSock("tcp", "127.0.0.1:15621")
+	server.SetProxyProtocol(ProxyRequired)
+
+	remote := make(chan net.Addr, 1)
+
+	server.OnConnection(func(conn TransportConnection) {
+		defer conn.Close()
+		remote <- conn.(*SockConnection).RemoteAddr()
+	})
+
+	go server.Serve()
+	defer server.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	source := &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 40000}
+	client, _ := NewSock("tcp", "127.0.0.1:15621")
+	client.SetProxyHeader(&ProxyHeader{Version: 2, Source: source})
+
+	conn, err := client.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	select {
+	case addr := <-remote:
+		if addr.String() != source.String() {
+			t.Fatal(addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection not handled")
+	}
+}
@@ -0,0 +1,82 @@
+package transports
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Backoff 建立连接失败后的重试间隔，第 n 次重试等待 Initial*Multiplier^n，不超过 Max
+// Jitter 为随机浮动的比例，避免大量客户端同时重连
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+	//Retries 最多重试的次数，为 0 表示失败后不再重试
+	Retries int
+}
+
+func NewBackoff() *Backoff {
+	backoff := new(Backoff)
+	backoff.Initial = 50 * time.Millisecond
+	backoff.Max = 2 * time.Second
+	backoff.Multiplier = 2
+	backoff.Jitter = 0.2
+	backoff.Retries = 3
+	return backoff
+}
+
+// Delay 第 attempt 次(从 0 开始)重试前等待的时间
+func (backoff *Backoff) Delay(attempt int) time.Duration {
+
+	delay := float64(backoff.Initial)
+
+	for i := 0; i < attempt && delay < float64(backoff.Max); i++ {
+		delay *= backoff.Multiplier
+	}
+
+	if backoff.Max > 0 && delay > float64(backoff.Max) {
+		delay = float64(backoff.Max)
+	}
+
+	if backoff.Jitter > 0 {
+		delay += delay * backoff.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+
+	return time.Duration(delay)
+}
+
+type ConnState int
+
+const (
+	//ConnStateConnected 连接已建立
+	ConnStateConnected ConnState = iota
+	//ConnStateRetrying 建立连接失败，等待后重试
+	ConnStateRetrying
+	//ConnStateFailed 重试次数用完，放弃建立连接
+	ConnStateFailed
+	//ConnStateBroken 已建立的连接出错，正在重新建立
+	ConnStateBroken
+)
+
+func (state ConnState) String() string {
+	switch state {
+	case ConnStateConnected:
+		return "connected"
+	case ConnStateRetrying:
+		return "retrying"
+	case ConnStateFailed:
+		return "failed"
+	case ConnStateBroken:
+		return "broken"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(state))
+}
+
+// ConnStateHandler 连接状态变化时同步调用，err 为导致该状态的错误
+type ConnStateHandler func(address string, state ConnState, err error)
@@ -0,0 +1,106 @@
+package transports
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weixinhost/yar.go"
+)
+
+func TestBackoffDelay(t *testing.T) {
+
+	backoff := NewBackoff()
+	backoff.Jitter = 0
+
+	if d := backoff.Delay(0); d != backoff.Initial {
+		t.Fatalf("first delay %s", d)
+	}
+
+	if d := backoff.Delay(2); d != 4*backoff.Initial {
+		t.Fatalf("third delay %s", d)
+	}
+
+	if d := backoff.Delay(100); d != backoff.Max {
+		t.Fatalf("delay not capped %s", d)
+	}
+
+	backoff.Jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		if d := backoff.Delay(0); d < backoff.Initial/2 || d > backoff.Initial*3/2 {
+			t.Fatalf("jitter out of range %s", d)
+		}
+	}
+}
+
+func TestSockReconnectOnBrokenConnection(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	frames := make(chan *yar.Header, 4)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					_, header, err := yar.ReadFrameBody(conn)
+					if err != nil {
+						return
+					}
+					frames <- header
+				}
+			}()
+		}
+	}()
+
+	var states []ConnState
+
+	sock, _ := NewSock("tcp", listener.Addr().String())
+	sock.SetReconnect(NewBackoff())
+	sock.OnStateChange(func(address string, state ConnState, err error) {
+		states = append(states, state)
+	})
+
+	conn, err := sock.Connection()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	//模拟复用的长连接已经断开
+	sockConn := conn.(*SockConnection)
+	sockConn.used = 1
+	sockConn.conn.Close()
+
+	r := yar.NewRequest()
+	r.Protocol.Layout = yar.DefaultLayout
+	r.Body = []byte("12345678json")
+
+	if err := conn.Send(r); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-frames:
+	case <-time.After(time.Second):
+		t.Fatal("request not resent")
+	}
+
+	if len(states) != 3 || states[1] != ConnStateBroken || states[2] != ConnStateConnected {
+		t.Fatalf("unexpected states %v", states)
+	}
+}
@@ -0,0 +1,40 @@
+package transports
+
+import (
+	"strings"
+	"sync"
+)
+
+// TransportFactory 根据地址(不含 scheme://)创建客户端使用的传输
+type TransportFactory func(address string) (Transport, error)
+
+var registry = struct {
+	lock      sync.RWMutex
+	factories map[string]TransportFactory
+}{factories: make(map[string]TransportFactory)}
+
+// Register 注册自定义的传输，client.NewClient 遇到内置协议之外的 scheme 时使用，传入 nil 取消注册
+func Register(scheme string, factory TransportFactory) {
+
+	scheme = strings.ToLower(scheme)
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if factory == nil {
+		delete(registry.factories, scheme)
+		return
+	}
+
+	registry.factories[scheme] = factory
+}
+
+// Lookup 查找 scheme 对应的传输
+func Lookup(scheme string) (TransportFactory, bool) {
+
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	factory, ok := registry.factories[strings.ToLower(scheme)]
+	return factory, ok
+}
@@ -1,13 +1,34 @@
 package transports
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"net"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/weixinhost/yar.go"
 )
 
 type SockConnection struct {
 	conn net.Conn
+	sock *Sock
+	//限速等待不超过读写的截止时间，并发请求会同时设置写超时，由 lock 保护
+	lock          sync.Mutex
+	deadline      time.Time
+	readDeadline  time.Time
+	writeDeadline time.Time
+	//used 连接上读到过对端的数据，此后写入失败多半是对端关闭了空闲的连接，可以重新建立连接
+	//reading 正在读取时(如并发模式下的读取)不重新建立连接
+	used    int32
+	reading int32
+	labels  map[string]string
+	//读写限速，为空时不限速
+	readLimit  *RateLimiter
+	writeLimit *RateLimiter
+	batch      writeBatch
 }
 
 func newSockConnection(conn net.Conn) *SockConnection {
@@ -17,11 +38,87 @@ func newSockConnection(conn net.Conn) *SockConnection {
 }
 
 func (conn *SockConnection) Read(buffer []byte) (n int, err error) {
-	return conn.conn.Read(buffer)
+	n, err = conn.conn.Read(buffer)
+	observeRead(conn.labels, n, err)
+
+	if n > 0 {
+		atomic.StoreInt32(&conn.used, 1)
+	}
+
+	if waitErr := conn.readLimit.Wait(n, conn.readBefore()); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	return n, err
 }
 
 func (conn *SockConnection) Write(buffer []byte) (n int, err error) {
-	return conn.conn.Write(buffer)
+
+	if err = conn.writeLimit.Wait(len(buffer), conn.writeBefore()); err != nil {
+		return 0, err
+	}
+
+	n, err = conn.conn.Write(buffer)
+	observeWrite(conn.labels, n, err)
+	return n, err
+}
+
+// WriteBuffers tcp/unix 连接上使用 writev 写出，tls 等连接合并后写出；并发写出的数据合并为一次写出
+func (conn *SockConnection) WriteBuffers(buffers net.Buffers) (int64, error) {
+	return conn.writeBuffers(buffers, conn.writeBefore())
+}
+
+// 合并写出时由负责写出的调用者按排队数据中最晚的截止时间设置写超时
+func (conn *SockConnection) writeBuffers(buffers net.Buffers, deadline time.Time) (int64, error) {
+
+	var written int64
+
+	err := conn.batch.write(func(pending net.Buffers, latest time.Time) error {
+
+		size := 0
+		for _, buffer := range pending {
+			size += len(buffer)
+		}
+
+		if err := conn.writeLimit.Wait(size, latest); err != nil {
+			return err
+		}
+
+		raw := conn.conn
+		raw.SetWriteDeadline(latest)
+
+		if pc, ok := raw.(*proxyConn); ok {
+			raw = pc.Conn
+		}
+
+		var n int64
+		var err error
+
+		switch raw.(type) {
+		case *net.TCPConn, *net.UnixConn:
+			n, err = pending.WriteTo(raw)
+		default:
+			n, err = writeJoined(conn.conn, pending)
+		}
+
+		observeWrite(conn.labels, int(n), err)
+		return err
+	}, buffers, deadline)
+
+	if err == nil {
+		for _, buffer := range buffers {
+			written += int64(len(buffer))
+		}
+	}
+
+	return written, err
+}
+
+// 并发写出请求时每个请求使用各自的截止时间，见 SendBefore
+func (conn *SockConnection) sendBefore(r *yar.Request, deadline time.Time) error {
+	r.Protocol.BodyLength = uint32(len(r.Body) + yar.PackagerLength)
+	_, err := conn.writeBuffers(net.Buffers{r.Protocol.WireBytes(), r.Body}, deadline)
+	return err
 }
 
 func (conn *SockConnection) Close() (err error) {
@@ -29,21 +126,120 @@ func (conn *SockConnection) Close() (err error) {
 }
 
 func (conn *SockConnection) SetReadTimeout(timeout time.Duration) {
-	now := time.Now()
-	conn.conn.SetReadDeadline(now.Add(timeout))
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	conn.readDeadline = time.Now().Add(timeout)
+	conn.conn.SetReadDeadline(conn.readDeadline)
 }
 
 func (conn *SockConnection) SetWriteTimeout(timeout time.Duration) {
-	now := time.Now()
-	conn.conn.SetWriteDeadline(now.Add(timeout))
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	conn.writeDeadline = time.Now().Add(timeout)
+	conn.conn.SetWriteDeadline(conn.writeDeadline)
+}
+
+func (conn *SockConnection) readBefore() time.Time {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.readDeadline
+}
+
+func (conn *SockConnection) writeBefore() time.Time {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.writeDeadline
+}
+
+// RemoteAddr 对端地址，开启 PROXY 协议时为 PROXY 头中的客户端地址
+func (conn *SockConnection) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+func (conn *SockConnection) LocalAddr() net.Addr {
+	return conn.conn.LocalAddr()
+}
+
+func (conn *SockConnection) SetDeadline(deadline time.Time) error {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	conn.deadline = deadline
+	conn.readDeadline = deadline
+	conn.writeDeadline = deadline
+	return conn.conn.SetDeadline(deadline)
+}
+
+// Send 复用的连接写入失败时(如对端已关闭的长连接)，开启重连后重新建立连接并重发一次
+// 写入失败时对端不会收到完整的帧，重发不会导致重复处理
+func (conn *SockConnection) Send(r *yar.Request) error {
+
+	err := send(conn, r)
+
+	if err == nil || atomic.LoadInt32(&conn.used) == 0 || atomic.LoadInt32(&conn.reading) == 1 || conn.sock == nil || conn.sock.backoff == nil {
+		return err
+	}
+
+	if reconnectErr := conn.reconnect(err); reconnectErr != nil {
+		return err
+	}
+
+	return send(conn, r)
+}
+
+func (conn *SockConnection) Recv(response *yar.Response) error {
+
+	atomic.StoreInt32(&conn.reading, 1)
+	err := recv(conn, response)
+	atomic.StoreInt32(&conn.reading, 0)
+	return err
+}
+
+func (conn *SockConnection) reconnect(cause error) error {
+
+	conn.sock.emit(ConnStateBroken, cause)
+	conn.conn.Close()
+
+	raw, err := conn.sock.connect()
+
+	if err != nil {
+		return err
+	}
+
+	conn.conn = raw
+	atomic.StoreInt32(&conn.used, 0)
+	conn.sock.initConnection(conn)
+
+	conn.lock.Lock()
+	if !conn.deadline.IsZero() {
+		raw.SetDeadline(conn.deadline)
+	}
+	conn.lock.Unlock()
+
+	return nil
 }
 
 type Sock struct {
-	hostname string
-	net      string
-	listener net.Listener
-	handler  ConnectionHandler
-	running  bool
+	hostname    string
+	net         string
+	listener    net.Listener
+	packet      net.PacketConn
+	handler     ConnectionHandler
+	running     int32
+	pool        *Pool
+	tlsConfig   *tls.Config
+	serverName  string
+	rootCAs     *x509.CertPool
+	dialContext DialContextFunc
+	dialTimeout time.Duration
+	sockOpt     *SocketOptions
+	backoff     *Backoff
+	onState     ConnStateHandler
+	proxyMode   ProxyMode
+	proxyHeader *ProxyHeader
+	labels      map[string]string
+	readLimit   *RateLimiter
+	writeLimit  *RateLimiter
+	listen      ListenFunc
 }
 
 func NewSock(net string, hostname string) (*Sock, error) {
@@ -51,6 +247,7 @@ func NewSock(net string, hostname string) (*Sock, error) {
 	tcp.hostname = hostname
 	tcp.handler = defaultHandler
 	tcp.net = net
+	tcp.labels = transportLabels("client", net, hostname)
 	return tcp, nil
 }
 
@@ -61,30 +258,61 @@ func (self *Sock) OnConnection(handler ConnectionHandler) {
 
 func (self *Sock) Serve() (err error) {
 
-	listener, err := net.Listen(self.net, self.hostname)
+	if self.net == "udp" || self.net == "unixgram" {
+		return self.serveDatagram()
+	}
+
+	listen := self.listen
+
+	if listen == nil {
+		listen = net.Listen
+	}
+
+	listener, err := listen(self.net, self.hostname)
 
 	if err != nil {
 		return err
 	}
 
+	//PROXY 头在 TLS 握手之前
+	if self.proxyMode != ProxyOff {
+		listener = &proxyListener{Listener: listener, mode: self.proxyMode}
+	}
+
+	if self.tlsConfig != nil {
+		listener = tls.NewListener(listener, self.tlsConfig)
+	}
+
 	self.listener = listener
-	self.running = true
+	atomic.StoreInt32(&self.running, 1)
+	labels := transportLabels("server", self.net, self.hostname)
 
 	defer self.listener.Close()
 
 	for {
 
-		if self.running == false {
+		if atomic.LoadInt32(&self.running) == 0 {
 			break
 		}
 
 		conn, err := self.listener.Accept()
 
 		if err != nil {
+			//Close 之后 Accept 返回的错误正常退出
+			if atomic.LoadInt32(&self.running) == 0 {
+				break
+			}
 			os.Exit(-1)
 		}
 
+		if err = self.sockOpt.Apply(conn); err != nil {
+			conn.Close()
+			continue
+		}
+
 		tcpConn := newSockConnection(conn)
+		tcpConn.labels = labels
+		tcpConn.readLimit, tcpConn.writeLimit = self.readLimit, self.writeLimit
 		self.initConnection(tcpConn)
 		go self.handler(tcpConn)
 	}
@@ -93,17 +321,206 @@ func (self *Sock) Serve() (err error) {
 
 }
 
+// SetPool 为客户端连接启用连接池，传入 nil 关闭连接池
+func (self *Sock) SetPool(config *PoolConfig) {
+
+	if self.pool != nil {
+		self.pool.Close()
+		self.pool = nil
+	}
+
+	if config != nil {
+		self.pool = NewPool(config, self.dial)
+		self.pool.labels = self.labels
+	}
+}
+
+func (self *Sock) Pool() *Pool {
+	return self.pool
+}
+
+// Connection 启用连接池时从池中取出连接，用完后通过 Release 归还
 func (self *Sock) Connection() (t TransportConnection, err error) {
-	conn, err := net.Dial(self.net, self.hostname)
 
-	if err != nil {
+	if self.pool != nil {
+		return self.pool.Get()
+	}
 
+	return self.dial()
+}
+
+// Release 归还可以继续使用的连接，未启用连接池时关闭连接
+func (self *Sock) Release(conn TransportConnection) {
+
+	if self.pool != nil {
+		self.pool.Put(conn)
+		return
+	}
+
+	conn.Close()
+}
+
+// SetTLSConfig 在连接上启用 TLS，服务端需要设置证书，客户端为空的 ServerName 使用连接的主机名
+func (self *Sock) SetTLSConfig(config *tls.Config) {
+	self.tlsConfig = config
+}
+
+// SetServerName 客户端 TLS 握手时使用的 SNI 及验证证书的主机名，用于以 IP 访问或名称与地址不一致的服务
+func (self *Sock) SetServerName(name string) {
+	self.serverName = name
+}
+
+// SetRootCAs 客户端验证服务端证书使用的 CA，代替系统的根证书，见 LoadCertPool
+func (self *Sock) SetRootCAs(pool *x509.CertPool) {
+	self.rootCAs = pool
+}
+
+// SetDialer 替换建立连接的函数，为空时使用 net.Dialer
+func (self *Sock) SetDialer(dial DialContextFunc) {
+	self.dialContext = dial
+}
+
+// SetListener 替换服务端创建监听的函数，为空时使用 net.Listen
+func (self *Sock) SetListener(listen ListenFunc) {
+	self.listen = listen
+}
+
+// SetDialTimeout 建立连接(包括 TLS 握手)的超时时间，为 0 表示不限制
+func (self *Sock) SetDialTimeout(timeout time.Duration) {
+	self.dialTimeout = timeout
+}
+
+// SetSocketOptions 设置新建连接(包括服务端接受的连接)的 socket 参数，传入 nil 保持默认
+func (self *Sock) SetSocketOptions(opt *SocketOptions) {
+	self.sockOpt = opt
+}
+
+// SetProxyProtocol 服务端解析连接开始的 PROXY 头(v1/v2)，需在 Serve 前调用
+func (self *Sock) SetProxyProtocol(mode ProxyMode) {
+	self.proxyMode = mode
+}
+
+// SetProxyHeader 客户端建立连接后先写出 PROXY 头，Source/Destination 为空时使用连接的本地/对端地址，传入 nil 关闭
+func (self *Sock) SetProxyHeader(header *ProxyHeader) {
+	self.proxyHeader = header
+}
+
+// SetThrottle 限制该传输上所有连接的读写速度(字节/秒)，为 0 表示不限速，只影响之后建立的连接
+func (self *Sock) SetThrottle(readRate int, writeRate int) {
+	self.readLimit = newRateLimiter(readRate)
+	self.writeLimit = newRateLimiter(writeRate)
+}
+
+// SetReconnect 建立连接失败时按 backoff 重试，复用的连接写入失败时重新建立连接，传入 nil 关闭重连
+func (self *Sock) SetReconnect(backoff *Backoff) {
+	self.backoff = backoff
+}
+
+// OnStateChange 设置连接状态变化的回调，用于记录日志或统计
+func (self *Sock) OnStateChange(handler ConnStateHandler) {
+	self.onState = handler
+}
+
+func (self *Sock) emit(state ConnState, err error) {
+	if self.onState != nil {
+		self.onState(self.hostname, state, err)
+	}
+}
+
+func (self *Sock) dial() (TransportConnection, error) {
+
+	conn, err := self.connect()
+
+	if err != nil {
 		return nil, err
 	}
 
 	tcpConn := newSockConnection(conn)
+	tcpConn.sock = self
+	tcpConn.labels = self.labels
+	tcpConn.readLimit, tcpConn.writeLimit = self.readLimit, self.writeLimit
 	self.initConnection(tcpConn)
-	return tcpConn, err
+	return tcpConn, nil
+}
+
+// 建立连接，开启重连时失败后按 backoff 重试
+func (self *Sock) connect() (net.Conn, error) {
+
+	for attempt := 0; ; attempt++ {
+
+		start := time.Now()
+		conn, err := self.connectOnce()
+		observeDial(self.labels, start, err)
+
+		if err == nil {
+			self.emit(ConnStateConnected, nil)
+			return conn, nil
+		}
+
+		if self.backoff == nil || attempt >= self.backoff.Retries {
+			self.emit(ConnStateFailed, err)
+			return nil, err
+		}
+
+		self.emit(ConnStateRetrying, err)
+		time.Sleep(self.backoff.Delay(attempt))
+	}
+}
+
+func (self *Sock) connectOnce() (net.Conn, error) {
+
+	conn, ctx, cancel, err := dialWithTimeout(self.dialContext, self.dialTimeout, self.net, self.hostname)
+
+	if err != nil {
+
+		return nil, err
+	}
+
+	defer cancel()
+
+	if err = self.sockOpt.Apply(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if self.proxyHeader != nil {
+
+		header := *self.proxyHeader
+
+		if header.Source == nil {
+			header.Source = conn.LocalAddr()
+		}
+
+		if header.Destination == nil {
+			header.Destination = conn.RemoteAddr()
+		}
+
+		if err = WriteProxyHeader(conn, &header); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if self.tlsConfig != nil {
+
+		config := withServerName(self.tlsConfig, self.serverName, self.rootCAs)
+
+		if len(config.ServerName) < 1 {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(self.hostname)
+		}
+
+		tlsConn := tls.Client(conn, config)
+
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	return conn, nil
 }
 
 func (self *Sock) initConnection(conn TransportConnection) {
@@ -115,6 +532,25 @@ func (self *Sock) initConnection(conn TransportConnection) {
 
 func (self *Sock) Stop() {
 
-	self.running = false
+	atomic.StoreInt32(&self.running, 0)
 
 }
+
+func (self *Sock) Close() error {
+
+	self.Stop()
+
+	if self.pool != nil {
+		self.pool.Close()
+	}
+
+	if self.packet != nil {
+		return self.packet.Close()
+	}
+
+	if self.listener != nil {
+		return self.listener.Close()
+	}
+
+	return nil
+}
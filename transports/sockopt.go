@@ -0,0 +1,101 @@
+package transports
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// SocketOptions 连接建立后设置的 socket 参数，零值表示保持系统(及 Go)的默认设置
+type SocketOptions struct {
+	//开启 Nagle 算法，默认关闭(TCP_NODELAY)，适合小包较多的场景
+	Nagle bool
+	//连接空闲多久后开始 keepalive 探测，小于 0 关闭 keepalive
+	KeepAlive time.Duration
+	//keepalive 探测间隔与次数
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+	//内核收发缓冲区大小(字节)
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// NewLatencySocketOptions 适合延迟敏感的小请求：关闭 Nagle，尽快发现断开的连接
+func NewLatencySocketOptions() *SocketOptions {
+	opt := new(SocketOptions)
+	opt.KeepAlive = 15 * time.Second
+	opt.KeepAliveInterval = 5 * time.Second
+	opt.KeepAliveCount = 3
+	return opt
+}
+
+// NewBulkSocketOptions 适合大数据量传输：开启 Nagle，加大收发缓冲区
+func NewBulkSocketOptions() *SocketOptions {
+	opt := new(SocketOptions)
+	opt.Nagle = true
+	opt.ReadBuffer = 1 << 20
+	opt.WriteBuffer = 1 << 20
+	return opt
+}
+
+// Apply 将参数设置到连接上，TLS 连接设置其底层连接，不支持的参数(如 unix 连接的 Nagle)跳过
+func (opt *SocketOptions) Apply(conn net.Conn) error {
+
+	if opt == nil {
+		return nil
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+
+	if pc, ok := conn.(*proxyConn); ok {
+		conn = pc.Conn
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+
+		if err := tcpConn.SetNoDelay(!opt.Nagle); err != nil {
+			return err
+		}
+
+		if opt.KeepAlive < 0 {
+			if err := tcpConn.SetKeepAlive(false); err != nil {
+				return err
+			}
+		} else if opt.KeepAlive > 0 || opt.KeepAliveInterval > 0 || opt.KeepAliveCount > 0 {
+			config := net.KeepAliveConfig{
+				Enable:   true,
+				Idle:     opt.KeepAlive,
+				Interval: opt.KeepAliveInterval,
+				Count:    opt.KeepAliveCount,
+			}
+			if err := tcpConn.SetKeepAliveConfig(config); err != nil {
+				return err
+			}
+		}
+	}
+
+	buffered, ok := conn.(interface {
+		SetReadBuffer(bytes int) error
+		SetWriteBuffer(bytes int) error
+	})
+
+	if !ok {
+		return nil
+	}
+
+	if opt.ReadBuffer > 0 {
+		if err := buffered.SetReadBuffer(opt.ReadBuffer); err != nil {
+			return err
+		}
+	}
+
+	if opt.WriteBuffer > 0 {
+		if err := buffered.SetWriteBuffer(opt.WriteBuffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
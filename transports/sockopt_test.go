@@ -0,0 +1,40 @@
+package transports
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSocketOptionsApply(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			var buffer [1]byte
+			conn.Read(buffer[:])
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	for _, opt := range []*SocketOptions{nil, NewLatencySocketOptions(), NewBulkSocketOptions(), &SocketOptions{KeepAlive: -1}} {
+		if err := opt.Apply(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
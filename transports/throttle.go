@@ -0,0 +1,70 @@
+package transports
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// RateLimiter 按字节数限速的令牌桶，同一个 RateLimiter 可以被多个连接共享
+type RateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 每秒最多 rate 字节，最多累积 1 秒的令牌
+func NewRateLimiter(rate int) *RateLimiter {
+	limiter := new(RateLimiter)
+	limiter.rate = float64(rate)
+	limiter.burst = float64(rate)
+	limiter.tokens = limiter.burst
+	limiter.last = time.Now()
+	return limiter
+}
+
+// Wait 取得 n 字节的令牌，令牌不足时等待，limiter 为空时不限速
+// deadline 不为零且等待会超过 deadline 时不等待，返回 os.ErrDeadlineExceeded，令牌不被扣除
+func (limiter *RateLimiter) Wait(n int, deadline time.Time) error {
+
+	if limiter == nil || n <= 0 || limiter.rate <= 0 {
+		return nil
+	}
+
+	limiter.lock.Lock()
+
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	limiter.last = now
+
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+
+	//先扣除令牌，不足的部分按速率等待，后来的调用在此之后排队
+	wait := time.Duration((float64(n) - limiter.tokens) / limiter.rate * float64(time.Second))
+
+	if wait > 0 && !deadline.IsZero() && now.Add(wait).After(deadline) {
+		limiter.lock.Unlock()
+		return os.ErrDeadlineExceeded
+	}
+
+	limiter.tokens -= float64(n)
+	limiter.lock.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return nil
+}
+
+// newRateLimiter rate 小于等于 0 时不限速
+func newRateLimiter(rate int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return NewRateLimiter(rate)
+}
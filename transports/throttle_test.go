@@ -0,0 +1,51 @@
+package transports
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	limiter := NewRateLimiter(10000)
+	start := time.Now()
+
+	//初始的令牌可以立即使用
+	limiter.Wait(10000, time.Time{})
+
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("burst waited")
+	}
+
+	limiter.Wait(2000, time.Time{})
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Fatal("unexpected wait", elapsed)
+	}
+
+	var unlimited *RateLimiter
+	unlimited.Wait(1<<30, time.Time{})
+}
+
+func TestRateLimiterDeadline(t *testing.T) {
+
+	limiter := NewRateLimiter(1000)
+	limiter.Wait(1000, time.Time{})
+	start := time.Now()
+
+	//需要等待 10 秒，超过截止时间时立即返回
+	if err := limiter.Wait(10000, time.Now().Add(100*time.Millisecond)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("expected deadline exceeded", err)
+	}
+
+	if time.Since(start) > 50*time.Millisecond {
+		t.Fatal("waited past the deadline")
+	}
+
+	//未扣除的令牌仍然可以使用
+	if err := limiter.Wait(50, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
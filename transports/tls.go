@@ -0,0 +1,88 @@
+package transports
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+)
+
+// NewTLSConfig 从 PEM 文件加载证书，certFile/keyFile 为本端证书，caFile 用于验证对端证书，均可为空
+// 作为服务端使用且设置了 caFile 时要求客户端提供证书
+func NewTLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+
+	config := new(tls.Config)
+
+	if len(certFile) > 0 || len(keyFile) > 0 {
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(caFile) > 0 {
+
+		pool, err := LoadCertPool(caFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = pool
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// LoadCertPool 从 PEM 文件加载 CA 证书，不包含系统的根证书
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+
+	pool := x509.NewCertPool()
+
+	for _, file := range files {
+
+		pem, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + file)
+		}
+	}
+
+	return pool, nil
+}
+
+// 为客户端的 TLS 配置设置 SNI 与 CA，未设置时返回原配置
+// 只设置 SNI 时保留原配置是否验证证书，设置 CA 时验证证书
+func withServerName(config *tls.Config, serverName string, rootCAs *x509.CertPool) *tls.Config {
+
+	if len(serverName) < 1 && rootCAs == nil {
+		return config
+	}
+
+	if config == nil {
+		config = new(tls.Config)
+	} else {
+		config = config.Clone()
+	}
+
+	if len(serverName) > 0 {
+		config.ServerName = serverName
+	}
+
+	if rootCAs != nil {
+		config.RootCAs = rootCAs
+		config.InsecureSkipVerify = false
+	}
+
+	return config
+}
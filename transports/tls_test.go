@@ -0,0 +1,75 @@
+package transports
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// httptest 的证书对 example.com、*.example.com 及 127.0.0.1 有效，记录客户端握手时的 SNI
+func newSNIServer(t *testing.T) (*httptest.Server, func() string) {
+
+	var lock sync.Mutex
+	var serverName string
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			lock.Lock()
+			serverName = hello.ServerName
+			lock.Unlock()
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, func() string {
+		lock.Lock()
+		defer lock.Unlock()
+		return serverName
+	}
+}
+
+func TestHttpClientServerName(t *testing.T) {
+
+	server, sni := newSNIServer(t)
+
+	//只设置 SNI 时与默认配置一样不验证证书
+	client, _ := NewHttpClient(server.URL)
+	client.SetServerName("sni.example.com")
+
+	if err := testHttpRequest(client); err != nil || sni() != "sni.example.com" {
+		t.Fatal(sni(), err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	client, _ = NewHttpClient(server.URL)
+	client.SetServerName("example.com")
+	client.SetRootCAs(pool)
+
+	if err := testHttpRequest(client); err != nil || sni() != "example.com" {
+		t.Fatal(sni(), err)
+	}
+
+	//设置 CA 后验证证书的主机名
+	client.SetServerName("example.org")
+
+	if err := testHttpRequest(client); err == nil {
+		t.Fatal("certificate for another host accepted")
+	}
+
+	client, _ = NewHttpClient(server.URL)
+	client.SetRootCAs(x509.NewCertPool())
+
+	if err := testHttpRequest(client); err == nil {
+		t.Fatal("certificate accepted without its root")
+	}
+}
@@ -3,6 +3,8 @@ package transports
 import (
 	"io"
 	"time"
+
+	"github.com/weixinhost/yar.go"
 )
 
 const (
@@ -17,6 +19,13 @@ type TransportConnection interface {
 
 	SetReadTimeout(timeout time.Duration)
 	SetWriteTimeout(timeout time.Duration)
+	//SetDeadline 设置读写的绝对截止时间
+	SetDeadline(deadline time.Time) error
+	//Send 按 r.Protocol.Layout 写出头部及 r.Body，BodyLength 由 r.Body 的长度得出
+	Send(r *yar.Request) error
+	//Recv 读取一个完整的帧，头部写入 response.Protocol，头部之后的数据写入 response.Body
+	//续帧模式下只读取第一帧，后续帧仍需从连接中读取
+	Recv(response *yar.Response) error
 }
 
 type ConnectionHandler func(conn TransportConnection)
@@ -25,8 +34,51 @@ type Transport interface {
 	Serve() (err error)
 	OnConnection(handler ConnectionHandler)
 	Connection() (conn TransportConnection, err error)
+	//Close 停止服务并释放监听的端口
+	Close() error
+}
+
+// PooledTransport 支持连接复用的传输，可以继续使用的连接通过 Release 归还
+type PooledTransport interface {
+	Transport
+	Release(conn TransportConnection)
 }
 
 func defaultHandler(conn TransportConnection) {
 	conn.Close()
 }
+
+func send(w io.Writer, r *yar.Request) error {
+	r.Protocol.BodyLength = uint32(len(r.Body) + yar.PackagerLength)
+	_, err := WriteBuffers(w, r.Protocol.WireBytes(), r.Body)
+	return err
+}
+
+// SendBefore 写出请求，写出的截止时间为 deadline，用于在同一连接上并发写出请求
+// sock 连接上合并写出的请求使用其中最晚的截止时间，不修改连接的写超时；其它连接设置写超时后写出
+func SendBefore(conn TransportConnection, r *yar.Request, deadline time.Time) error {
+
+	if sender, ok := conn.(deadlineSender); ok {
+		return sender.sendBefore(r, deadline)
+	}
+
+	conn.SetWriteTimeout(time.Until(deadline))
+	return conn.Send(r)
+}
+
+type deadlineSender interface {
+	sendBefore(r *yar.Request, deadline time.Time) error
+}
+
+func recv(r io.Reader, response *yar.Response) error {
+
+	body, header, err := yar.ReadFrameBody(r)
+
+	if err != nil {
+		return err
+	}
+
+	response.Protocol = header
+	response.Body = body
+	return nil
+}
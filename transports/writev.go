@@ -0,0 +1,114 @@
+package transports
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// BuffersWriter 一次写出多段数据的连接，并发调用时每次调用的数据完整写出，不会与其它调用交错
+type BuffersWriter interface {
+	WriteBuffers(buffers net.Buffers) (int64, error)
+}
+
+// WriteBuffers 将头部与数据等多段数据一次写出，避免分成多个小包
+// w 不支持 BuffersWriter 时合并为一次 Write
+func WriteBuffers(w io.Writer, buffers ...[]byte) (int64, error) {
+
+	if bw, ok := w.(BuffersWriter); ok {
+		return bw.WriteBuffers(buffers)
+	}
+
+	return writeJoined(w, buffers)
+}
+
+func writeJoined(w io.Writer, buffers [][]byte) (int64, error) {
+
+	if len(buffers) == 1 {
+		n, err := w.Write(buffers[0])
+		return int64(n), err
+	}
+
+	size := 0
+
+	for _, buffer := range buffers {
+		size += len(buffer)
+	}
+
+	data := make([]byte, 0, size)
+
+	for _, buffer := range buffers {
+		data = append(data, buffer...)
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// writeBatch 合并同一连接上并发写出的数据(如单连接并发请求)
+// 第一个调用者负责写出，期间到达的数据排队，由它在下一次 writev 中一并写出
+// 每次写出使用排队的数据中最晚的截止时间，一个请求较早的截止时间不会使其它请求写出失败
+type writeBatch struct {
+	lock     sync.Mutex
+	writing  bool
+	pending  net.Buffers
+	deadline time.Time
+	waiters  []chan error
+}
+
+func (batch *writeBatch) write(write func(buffers net.Buffers, deadline time.Time) error, buffers net.Buffers, deadline time.Time) error {
+
+	done := make(chan error, 1)
+
+	batch.lock.Lock()
+
+	if len(batch.waiters) == 0 {
+		batch.deadline = deadline
+	} else {
+		batch.deadline = laterDeadline(batch.deadline, deadline)
+	}
+
+	batch.pending = append(batch.pending, buffers...)
+	batch.waiters = append(batch.waiters, done)
+
+	if batch.writing {
+		batch.lock.Unlock()
+		return <-done
+	}
+
+	batch.writing = true
+
+	for len(batch.waiters) > 0 {
+
+		pending, waiters, latest := batch.pending, batch.waiters, batch.deadline
+		batch.pending, batch.waiters = nil, nil
+		batch.lock.Unlock()
+
+		err := write(pending, latest)
+
+		for _, waiter := range waiters {
+			waiter <- err
+		}
+
+		batch.lock.Lock()
+	}
+
+	batch.writing = false
+	batch.lock.Unlock()
+	return <-done
+}
+
+// 零值表示不限制，晚于任何截止时间
+func laterDeadline(a time.Time, b time.Time) time.Time {
+
+	if a.IsZero() || b.IsZero() {
+		return time.Time{}
+	}
+
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
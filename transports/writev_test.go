@@ -0,0 +1,147 @@
+package transports
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteBuffersConcurrent(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	raw, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer, err := listener.Accept()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer peer.Close()
+
+	conn := newSockConnection(raw)
+	defer conn.Close()
+
+	const writers = 50
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var length [4]byte
+			binary.BigEndian.PutUint32(length[:], uint32(100+i))
+			if _, err := WriteBuffers(conn, length[:], bytes.Repeat([]byte{byte(i)}, 100+i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	//每一段数据必须完整到达，不能与其它写出者的数据交错
+	seen := make(map[int]bool)
+
+	for len(seen) < writers {
+
+		var length [4]byte
+
+		if _, err := io.ReadFull(peer, length[:]); err != nil {
+			t.Fatal(err)
+		}
+
+		body := make([]byte, binary.BigEndian.Uint32(length[:]))
+
+		if _, err := io.ReadFull(peer, body); err != nil {
+			t.Fatal(err)
+		}
+
+		i := len(body) - 100
+
+		if i < 0 || i >= writers || seen[i] || !bytes.Equal(body, bytes.Repeat([]byte{byte(i)}, len(body))) {
+			t.Fatal("interleaved write", len(body))
+		}
+
+		seen[i] = true
+	}
+
+	wg.Wait()
+}
+
+func TestWriteBatchDeadline(t *testing.T) {
+
+	var batch writeBatch
+	started := make(chan struct{})
+	release := make(chan struct{})
+	deadlines := make(chan time.Time, 2)
+
+	write := func(buffers net.Buffers, deadline time.Time) error {
+		deadlines <- deadline
+		if len(deadlines) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}
+
+	now := time.Now()
+	first := now.Add(time.Second)
+	done := make(chan error, 3)
+
+	go func() {
+		done <- batch.write(write, net.Buffers{[]byte("a")}, first)
+	}()
+
+	<-started
+
+	//第一次写出期间排队的两个请求在下一次写出中合并，使用较晚的截止时间
+	go func() {
+		done <- batch.write(write, net.Buffers{[]byte("b")}, now.Add(time.Millisecond))
+	}()
+	go func() {
+		done <- batch.write(write, net.Buffers{[]byte("c")}, now.Add(time.Minute))
+	}()
+
+	for {
+		batch.lock.Lock()
+		queued := len(batch.waiters)
+		batch.lock.Unlock()
+		if queued == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := <-deadlines; !got.Equal(first) {
+		t.Fatal("first write deadline", got)
+	}
+
+	if got := <-deadlines; !got.Equal(now.Add(time.Minute)) {
+		t.Fatal("batched write should use the latest deadline", got)
+	}
+
+	if !laterDeadline(now, time.Time{}).IsZero() {
+		t.Fatal("zero deadline should mean no limit")
+	}
+}
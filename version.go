@@ -0,0 +1,39 @@
+package yar
+
+// 协议版本保存在头部的 Version 字段中
+// 客户端在请求中声明自身支持的最高版本，服务端返回双方都支持的版本，
+// 客户端在得知对端版本之前不使用任何扩展，从而兼容未升级的 php-yar
+const (
+	//ProtocolVersionLegacy php-yar 原始协议，Reserved 字段不携带任何标志
+	ProtocolVersionLegacy uint16 = 0
+	//ProtocolVersionFlags 支持 Reserved 字段中的分块、压缩标志
+	ProtocolVersionFlags uint16 = 1
+	//ProtocolVersionContinuation 支持以多个续帧传输超大的数据
+	ProtocolVersionContinuation uint16 = 2
+	//ProtocolVersion 当前实现支持的最高版本
+	ProtocolVersion = ProtocolVersionContinuation
+)
+
+// NegotiateVersion 返回双方都支持的协议版本
+func NegotiateVersion(local uint16, remote uint16) uint16 {
+
+	if local < remote {
+		return local
+	}
+
+	return remote
+}
+
+// RequiredVersion 返回解析 reserved 中的标志所需的最低协议版本
+func RequiredVersion(reserved uint32) uint16 {
+
+	if reserved&FlagContinuation != 0 {
+		return ProtocolVersionContinuation
+	}
+
+	if reserved != 0 {
+		return ProtocolVersionFlags
+	}
+
+	return ProtocolVersionLegacy
+}
@@ -0,0 +1,47 @@
+package yar
+
+import "testing"
+
+func TestNegotiateVersion(t *testing.T) {
+
+	tests := []struct {
+		local    uint16
+		remote   uint16
+		expected uint16
+	}{
+		{ProtocolVersionLegacy, ProtocolVersionLegacy, ProtocolVersionLegacy},
+		{ProtocolVersionLegacy, ProtocolVersion, ProtocolVersionLegacy},
+		{ProtocolVersion, ProtocolVersionLegacy, ProtocolVersionLegacy},
+		{ProtocolVersionFlags, ProtocolVersionContinuation, ProtocolVersionFlags},
+		{ProtocolVersionContinuation, ProtocolVersionFlags, ProtocolVersionFlags},
+		{ProtocolVersion, ProtocolVersion, ProtocolVersion},
+		//更新的对端按本端支持的最高版本处理
+		{ProtocolVersion, ProtocolVersion + 1, ProtocolVersion},
+	}
+
+	for _, test := range tests {
+		if v := NegotiateVersion(test.local, test.remote); v != test.expected {
+			t.Fatal(test.local, test.remote, v)
+		}
+	}
+}
+
+func TestRequiredVersion(t *testing.T) {
+
+	tests := []struct {
+		reserved uint32
+		expected uint16
+	}{
+		{0, ProtocolVersionLegacy},
+		{FlagChunked, ProtocolVersionFlags},
+		{FlagChecksum | FlagPersistent, ProtocolVersionFlags},
+		{FlagContinuation, ProtocolVersionContinuation},
+		{FlagContinuation | FlagChecksum, ProtocolVersionContinuation},
+	}
+
+	for _, test := range tests {
+		if v := RequiredVersion(test.reserved); v != test.expected {
+			t.Fatal(test.reserved, v)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package wiretest 提供 yar 请求、返回的标准字节样例及断言方法，用于校验与 php-yar 的互通
+//
+// 样例按照 php-yar 的 yar_header_t 布局与 yar_request_pack/yar_response_pack 的字段顺序构造，
+// 对应 php-yar 客户端、服务端在默认配置下应当发出的字节流
+//
+// 样例由本包按上述布局自行生成，并非从 php-yar 抓取，不能发现与 php-yar 实现本身的差异；
+// 从 php-yar 抓取的样例应注明 php-yar 的版本后加入 Fixtures
+package wiretest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/weixinhost/yar.go"
+	"github.com/weixinhost/yar.go/packager"
+	"github.com/weixinhost/yar.go/protocol"
+)
+
+type Fixture struct {
+	Name     string
+	Packager string
+	//Frame 完整的帧，包含头部、打包协议名与数据
+	Frame []byte
+	//Request 与 Response 中只有一个不为空，为 Frame 解包后应得到的值
+	Request  *yar.Request
+	Response *yar.Response
+}
+
+// 按 php-yar 头部布局构造帧，php-yar 的 provider 与 token 默认为空，version 为 0
+func frame(id uint32, packagerName string, body string) []byte {
+
+	data := make([]byte, yar.HeaderLength, yar.HeaderLength+len(body))
+	binary.BigEndian.PutUint32(data[0:], id)
+	binary.BigEndian.PutUint32(data[6:], uint32(yar.MagicNumber))
+	binary.BigEndian.PutUint32(data[78:], uint32(yar.PackagerLength+len(body)))
+	copy(data[82:], packagerName)
+	return append(data, body...)
+}
+
+func request(id uint32, method string, params ...interface{}) *yar.Request {
+	r := yar.NewRequest()
+	r.Id = id
+	r.Method = method
+	r.Params = append([]interface{}{}, params...)
+	return r
+}
+
+func response(id uint32, status yar.ErrorType, retval interface{}, err string) *yar.Response {
+	r := yar.NewResponse()
+	r.Id = id
+	r.Status = status
+	r.Retval = retval
+	r.Error = err
+	return r
+}
+
+// Fixtures 返回所有样例，目前只有 json 打包协议可用，json 解包时数字为 json.Number
+func Fixtures() []Fixture {
+	return []Fixture{
+		{
+			Name:     "json/request",
+			Packager: "JSON",
+			Frame:    frame(0x1d2c3b4a, "JSON", `{"i":489438026,"m":"echo","p":["hello",3,true]}`),
+			Request:  request(0x1d2c3b4a, "echo", "hello", json.Number("3"), true),
+		},
+		{
+			Name:     "json/request-no-params",
+			Packager: "JSON",
+			Frame:    frame(7, "JSON", `{"i":7,"m":"ping","p":[]}`),
+			Request:  request(7, "ping"),
+		},
+		{
+			Name:     "json/response",
+			Packager: "JSON",
+			Frame:    frame(0x1d2c3b4a, "JSON", `{"i":489438026,"s":0,"r":{"name":"yar","list":[1,2]}}`),
+			Response: response(0x1d2c3b4a, yar.ERR_OKEY, map[string]interface{}{"name": "yar", "list": []interface{}{json.Number("1"), json.Number("2")}}, ""),
+		},
+		{
+			Name:     "json/response-exception",
+			Packager: "JSON",
+			Frame:    frame(7, "JSON", `{"i":7,"s":64,"e":"call undefined api:ping"}`),
+			Response: response(7, yar.ERR_EXCEPTION, nil, "call undefined api:ping"),
+		},
+	}
+}
+
+// AssertDecode 解析样例的帧，检查头部与解包后的值
+func AssertDecode(t testing.TB, f Fixture) {
+
+	t.Helper()
+
+	header, body, err := protocol.DecodeFrame(f.Frame)
+
+	if err != nil {
+		t.Fatalf("%s: decode frame: %v", f.Name, err)
+	}
+
+	if header.MagicNumber != yar.MagicNumber || header.PackagerString() != f.Packager {
+		t.Fatalf("%s: unexpected header %s", f.Name, protocol.Describe(header))
+	}
+
+	if f.Request != nil {
+
+		r := yar.NewRequest()
+
+		if err = packager.Unpack([]byte(f.Packager), body, r); err != nil {
+			t.Fatalf("%s: unpack request: %v", f.Name, err)
+		}
+
+		AssertRequest(t, f.Name, r, f.Request)
+
+		if header.Id != f.Request.Id {
+			t.Fatalf("%s: header id %d, want %d", f.Name, header.Id, f.Request.Id)
+		}
+	}
+
+	if f.Response != nil {
+
+		r := yar.NewResponse()
+
+		if err = packager.Unpack([]byte(f.Packager), body, r); err != nil {
+			t.Fatalf("%s: unpack response: %v", f.Name, err)
+		}
+
+		AssertResponse(t, f.Name, r, f.Response)
+
+		if header.Id != f.Response.Id {
+			t.Fatalf("%s: header id %d, want %d", f.Name, header.Id, f.Response.Id)
+		}
+	}
+}
+
+// AssertRoundTrip 解析样例后重新编码再解析，检查结果不变
+func AssertRoundTrip(t testing.TB, f Fixture) {
+
+	t.Helper()
+
+	header, body, err := protocol.DecodeFrame(f.Frame)
+
+	if err != nil {
+		t.Fatalf("%s: decode frame: %v", f.Name, err)
+	}
+
+	var v interface{}
+
+	if f.Request != nil {
+		v = yar.NewRequest()
+	} else {
+		v = yar.NewResponse()
+	}
+
+	if err = packager.Unpack([]byte(f.Packager), body, v); err != nil {
+		t.Fatalf("%s: unpack: %v", f.Name, err)
+	}
+
+	packed, err := packager.Pack([]byte(f.Packager), v)
+
+	if err != nil {
+		t.Fatalf("%s: pack: %v", f.Name, err)
+	}
+
+	reencoded := Fixture{Name: f.Name + "/reencoded", Packager: f.Packager, Frame: protocol.EncodeFrame(header, packed), Request: f.Request, Response: f.Response}
+	AssertDecode(t, reencoded)
+}
+
+func AssertRequest(t testing.TB, name string, got *yar.Request, want *yar.Request) {
+
+	t.Helper()
+
+	if got.Id != want.Id || got.Method != want.Method || !reflect.DeepEqual(got.Params, want.Params) {
+		t.Fatalf("%s: request %d %s %#v, want %d %s %#v", name, got.Id, got.Method, got.Params, want.Id, want.Method, want.Params)
+	}
+}
+
+func AssertResponse(t testing.TB, name string, got *yar.Response, want *yar.Response) {
+
+	t.Helper()
+
+	if got.Id != want.Id || got.Status != want.Status || got.Error != want.Error || !reflect.DeepEqual(got.Retval, want.Retval) {
+		t.Fatalf("%s: response %d %d %q %#v, want %d %d %q %#v", name, got.Id, got.Status, got.Error, got.Retval, want.Id, want.Status, want.Error, want.Retval)
+	}
+}
@@ -0,0 +1,11 @@
+package wiretest
+
+import "testing"
+
+func TestFixtures(t *testing.T) {
+
+	for _, f := range Fixtures() {
+		AssertDecode(t, f)
+		AssertRoundTrip(t, f)
+	}
+}